@@ -0,0 +1,10 @@
+package web
+
+// AfterMiddleware is an optional interface that a Middleware can implement to
+// be notified once the route handler has finished.  After is only called for
+// middleware whose Handle method returned true, and is called in the reverse
+// order to Handle.  After is called even if the handler panics.
+type AfterMiddleware interface {
+	Middleware
+	After(ctx *Context)
+}
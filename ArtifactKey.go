@@ -0,0 +1,35 @@
+package web
+
+// ArtifactKey is a typed key for a middleware artifact.  Middleware can declare
+// a key once, such as var UserKey = NewArtifactKey[*User]("user"), and then get
+// and set the artifact through the key without type assertions.  Artifacts are
+// stored alongside those set with SetMiddlewareArtifact, under the name of the
+// key.
+type ArtifactKey[T any] struct {
+	name string
+}
+
+// NewArtifactKey creates a new ArtifactKey with the provided name.
+func NewArtifactKey[T any](name string) ArtifactKey[T] {
+	return ArtifactKey[T]{
+		name: name,
+	}
+}
+
+// Name returns the name under which the artifact is stored.
+func (k ArtifactKey[T]) Name() string {
+	return k.name
+}
+
+// Get retrieves the artifact for the key from the provided Context.  The zero
+// value of T is returned if the artifact does not exist, or if it was set
+// through SetMiddlewareArtifact with a value of a different type.
+func (k ArtifactKey[T]) Get(ctx *Context) T {
+	v, _ := ctx.GetMiddlewareArtifact(k.name).(T)
+	return v
+}
+
+// Set sets the artifact for the key on the provided Context.
+func (k ArtifactKey[T]) Set(ctx *Context, value T) {
+	ctx.SetMiddlewareArtifact(k.name, value)
+}
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/ljpx/test"
+)
+
+func TestArtifactKeySetThenGet(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	key := NewArtifactKey[*testResponseModel]("model")
+	model := &testResponseModel{Message: "Hello, World!"}
+
+	// Act.
+	key.Set(fixture.x, model)
+	actual := key.Get(fixture.x)
+
+	// Assert.
+	test.That(t, actual).IsEqualTo(model)
+	test.That(t, fixture.x.GetMiddlewareArtifact("model")).IsEqualTo(model)
+}
+
+func TestArtifactKeyMissReturnsZeroValue(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	countKey := NewArtifactKey[int]("count")
+	modelKey := NewArtifactKey[*testResponseModel]("model")
+
+	// Act.
+	count := countKey.Get(fixture.x)
+	model := modelKey.Get(fixture.x)
+
+	// Assert.
+	test.That(t, count).IsEqualTo(0)
+	test.That(t, model == nil).IsTrue()
+}
+
+func TestArtifactKeyMismatchedTypeReturnsZeroValue(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.SetMiddlewareArtifact("count", "three")
+	key := NewArtifactKey[int]("count")
+
+	// Act.
+	count := key.Get(fixture.x)
+
+	// Assert.
+	test.That(t, count).IsEqualTo(0)
+}
@@ -0,0 +1,127 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+)
+
+// BufferingResponseWriter wraps a standard http.ResponseWriter, capturing the
+// status code, headers and body of the response in memory rather than writing
+// them through.  The captured response can be inspected and modified before
+// being written to the underlying http.ResponseWriter with Commit.
+type BufferingResponseWriter struct {
+	w            http.ResponseWriter
+	header       http.Header
+	statusCode   int
+	body         *bytes.Buffer
+	hasCommitted bool
+}
+
+// NewBufferingResponseWriter creates a new BufferingResponseWriter with the
+// provided underlying http.ResponseWriter.  Any headers already set on the
+// underlying http.ResponseWriter are copied into the buffer.
+func NewBufferingResponseWriter(w http.ResponseWriter) *BufferingResponseWriter {
+	return &BufferingResponseWriter{
+		w:      w,
+		header: w.Header().Clone(),
+		body:   &bytes.Buffer{},
+	}
+}
+
+var _ http.ResponseWriter = &BufferingResponseWriter{}
+var _ http.Flusher = &BufferingResponseWriter{}
+
+// Header returns the buffered headers.
+func (brw *BufferingResponseWriter) Header() http.Header {
+	return brw.header
+}
+
+// Write appends to the buffered body.
+func (brw *BufferingResponseWriter) Write(b []byte) (int, error) {
+	if brw.hasCommitted {
+		return brw.w.Write(b)
+	}
+
+	return brw.body.Write(b)
+}
+
+// WriteHeader records the status code if it has not already been recorded.
+func (brw *BufferingResponseWriter) WriteHeader(statusCode int) {
+	if brw.statusCode == 0 {
+		brw.statusCode = statusCode
+	}
+}
+
+// StatusCode returns the buffered status code.  If WriteHeader has not been
+// called, StatusCode will return http.StatusOK.
+func (brw *BufferingResponseWriter) StatusCode() int {
+	if brw.statusCode == 0 {
+		return http.StatusOK
+	}
+
+	return brw.statusCode
+}
+
+// SetStatusCode overrides the buffered status code.
+func (brw *BufferingResponseWriter) SetStatusCode(statusCode int) {
+	brw.statusCode = statusCode
+}
+
+// Body returns the buffered body.
+func (brw *BufferingResponseWriter) Body() []byte {
+	return brw.body.Bytes()
+}
+
+// SetBody replaces the buffered body.  If a Content-Length header has been set,
+// it is updated to reflect the length of the new body.
+func (brw *BufferingResponseWriter) SetBody(body []byte) {
+	brw.body = bytes.NewBuffer(body)
+
+	if brw.header.Get("Content-Length") != "" {
+		brw.header.Set("Content-Length", fmt.Sprintf("%v", len(body)))
+	}
+}
+
+// HasCommitted returns true if Commit has been called.
+func (brw *BufferingResponseWriter) HasCommitted() bool {
+	return brw.hasCommitted
+}
+
+// Flush implements http.Flusher.  Before Commit has been called, the response is
+// held in memory and Flush has no effect.  After Commit, Flush flushes the
+// underlying http.ResponseWriter, if it supports it.
+func (brw *BufferingResponseWriter) Flush() {
+	if !brw.hasCommitted {
+		return
+	}
+
+	if flusher, ok := brw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Commit writes the buffered status code, headers and body to the underlying
+// http.ResponseWriter.  Any writes made after Commit go directly to the
+// underlying http.ResponseWriter.  Calling Commit more than once has no effect.
+func (brw *BufferingResponseWriter) Commit() error {
+	if brw.hasCommitted {
+		return nil
+	}
+
+	brw.hasCommitted = true
+
+	header := brw.w.Header()
+	for name := range header {
+		delete(header, name)
+	}
+
+	for name, values := range brw.header {
+		header[name] = values
+	}
+
+	brw.w.WriteHeader(brw.StatusCode())
+	_, err := brw.w.Write(brw.body.Bytes())
+
+	return err
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ljpx/test"
+)
+
+type BufferingResponseWriterFixture struct {
+	w *httptest.ResponseRecorder
+	x *BufferingResponseWriter
+}
+
+func SetupBufferingResponseWriterFixture() *BufferingResponseWriterFixture {
+	fixture := &BufferingResponseWriterFixture{}
+	fixture.w = httptest.NewRecorder()
+	fixture.x = NewBufferingResponseWriter(fixture.w)
+
+	return fixture
+}
+
+func TestBufferingResponseWriterDoesNotWriteThrough(t *testing.T) {
+	// Arrange.
+	fixture := SetupBufferingResponseWriterFixture()
+
+	// Act.
+	fixture.x.Header().Set("X-Test-Header", "test-value")
+	fixture.x.WriteHeader(http.StatusCreated)
+	fixture.x.Write([]byte("Hello, World!"))
+
+	// Assert.
+	test.That(t, fixture.w.Header().Get("X-Test-Header")).IsEqualTo("")
+	test.That(t, fixture.w.Body.Len()).IsEqualTo(0)
+	test.That(t, fixture.x.StatusCode()).IsEqualTo(http.StatusCreated)
+	test.That(t, string(fixture.x.Body())).IsEqualTo("Hello, World!")
+}
+
+func TestBufferingResponseWriterMutateBodyThenCommit(t *testing.T) {
+	// Arrange.
+	fixture := SetupBufferingResponseWriterFixture()
+	fixture.x.Header().Set("Content-Length", "13")
+	fixture.x.WriteHeader(http.StatusCreated)
+	fixture.x.Write([]byte("Hello, World!"))
+
+	// Act.
+	fixture.x.SetBody([]byte("Goodbye!"))
+	err := fixture.x.Commit()
+
+	// Assert.
+	test.That(t, err).IsNil()
+	test.That(t, fixture.x.HasCommitted()).IsTrue()
+
+	res := fixture.w.Result()
+	raw, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusCreated)
+	test.That(t, res.Header.Get("Content-Length")).IsEqualTo("8")
+	test.That(t, string(raw)).IsEqualTo("Goodbye!")
+}
+
+func TestBufferingResponseWriterReturns200ByDefault(t *testing.T) {
+	// Arrange.
+	fixture := SetupBufferingResponseWriterFixture()
+
+	// Act.
+	fixture.x.Commit()
+
+	// Assert.
+	test.That(t, fixture.x.StatusCode()).IsEqualTo(http.StatusOK)
+	test.That(t, fixture.w.Result().StatusCode).IsEqualTo(http.StatusOK)
+}
+
+func TestBufferingResponseWriterWritesThroughAfterCommit(t *testing.T) {
+	// Arrange.
+	fixture := SetupBufferingResponseWriterFixture()
+	fixture.x.Write([]byte("Hello, "))
+	fixture.x.Commit()
+
+	// Act.
+	fixture.x.Write([]byte("World!"))
+	fixture.x.Commit()
+
+	// Assert.
+	test.That(t, fixture.w.Body.String()).IsEqualTo("Hello, World!")
+}
+
+func TestBufferingResponseWriterFlushBeforeCommit(t *testing.T) {
+	// Arrange.
+	fixture := SetupBufferingResponseWriterFixture()
+	fixture.x.Write([]byte("Hello, World!"))
+
+	// Act.
+	fixture.x.Flush()
+
+	// Assert.
+	test.That(t, fixture.w.Flushed).IsFalse()
+	test.That(t, fixture.w.Body.Len()).IsEqualTo(0)
+}
+
+func TestBufferingResponseWriterFlushAfterCommit(t *testing.T) {
+	// Arrange.
+	fixture := SetupBufferingResponseWriterFixture()
+	fixture.x.Write([]byte("Hello, World!"))
+	fixture.x.Commit()
+
+	// Act.
+	fixture.x.Flush()
+
+	// Assert.
+	test.That(t, fixture.w.Flushed).IsTrue()
+	test.That(t, fixture.w.Body.String()).IsEqualTo("Hello, World!")
+}
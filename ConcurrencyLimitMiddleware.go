@@ -0,0 +1,63 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ConcurrencyLimitMiddleware is a Middleware that limits the number of requests
+// that can be handled concurrently.  Requests that cannot acquire a slot within
+// the acquire timeout receive a ServiceUnavailable response.  Slots are released
+// by the After hook, which runs even if the route handler panics.
+// ConcurrencyLimitMiddleware is thread-safe, and a single instance should be
+// shared across all requests for the routes it protects.
+type ConcurrencyLimitMiddleware struct {
+	slots          chan struct{}
+	acquireTimeout time.Duration
+}
+
+var _ AfterMiddleware = &ConcurrencyLimitMiddleware{}
+
+// NewConcurrencyLimitMiddleware creates a new ConcurrencyLimitMiddleware that
+// permits up to max concurrent requests, waiting up to acquireTimeout for a slot
+// to become available.
+func NewConcurrencyLimitMiddleware(max int, acquireTimeout time.Duration) *ConcurrencyLimitMiddleware {
+	return &ConcurrencyLimitMiddleware{
+		slots:          make(chan struct{}, max),
+		acquireTimeout: acquireTimeout,
+	}
+}
+
+// Handle acquires a slot for the request.  If no slot becomes available within
+// the acquire timeout, a ServiceUnavailable response is sent and false is
+// returned.
+func (m *ConcurrencyLimitMiddleware) Handle(ctx *Context) bool {
+	select {
+	case m.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if m.acquireTimeout > 0 {
+		timer := time.NewTimer(m.acquireTimeout)
+		defer timer.Stop()
+
+		select {
+		case m.slots <- struct{}{}:
+			return true
+		case <-timer.C:
+		case <-ctx.r.Context().Done():
+		}
+	}
+
+	detail := fmt.Sprintf("The maximum of %v concurrent requests has been reached.  Please try again later.", cap(m.slots))
+	ctx.RespondWithRetryableProblem(http.StatusServiceUnavailable, "concurrency-limit-exceeded", detail, time.Second)
+
+	return false
+}
+
+// After releases the slot acquired by Handle.
+func (m *ConcurrencyLimitMiddleware) After(ctx *Context) {
+	<-m.slots
+}
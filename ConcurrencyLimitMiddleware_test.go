@@ -0,0 +1,116 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ljpx/di"
+	"github.com/ljpx/logging"
+	"github.com/ljpx/test"
+)
+
+type ConcurrencyLimitMiddlewareFixture struct {
+	x       *ConcurrencyLimitMiddleware
+	route   *testConcurrencyLimitedRoute
+	handler http.Handler
+}
+
+func SetupConcurrencyLimitMiddlewareFixture() *ConcurrencyLimitMiddlewareFixture {
+	fixture := &ConcurrencyLimitMiddlewareFixture{}
+	fixture.x = NewConcurrencyLimitMiddleware(1, 10*time.Millisecond)
+	fixture.route = &testConcurrencyLimitedRoute{middleware: fixture.x}
+
+	builder := NewHandlerBuilder(di.NewContainer(), logging.NewDummyLogger(), &Config{
+		ProblemDetailsTypePrefix: "https://testi.ng",
+	})
+
+	builder.Use(fixture.route)
+	fixture.handler = builder.Build()
+
+	return fixture
+}
+
+func (fixture *ConcurrencyLimitMiddlewareFixture) get() *http.Response {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/limited", nil)
+	fixture.handler.ServeHTTP(w, r)
+
+	return w.Result()
+}
+
+func TestConcurrencyLimitMiddlewareAllowsSequentialRequests(t *testing.T) {
+	// Arrange.
+	fixture := SetupConcurrencyLimitMiddlewareFixture()
+
+	// Act.
+	first := fixture.get()
+	second := fixture.get()
+
+	// Assert.
+	test.That(t, first.StatusCode).IsEqualTo(http.StatusNoContent)
+	test.That(t, second.StatusCode).IsEqualTo(http.StatusNoContent)
+	test.That(t, len(fixture.x.slots)).IsEqualTo(0)
+}
+
+func TestConcurrencyLimitMiddlewareRejectsWhenFull(t *testing.T) {
+	// Arrange.
+	fixture := SetupConcurrencyLimitMiddlewareFixture()
+	fixture.x.slots <- struct{}{}
+
+	// Act.
+	res := fixture.get()
+
+	// Assert.
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusServiceUnavailable)
+	test.That(t, res.Header.Get("Retry-After")).IsEqualTo("1")
+	test.That(t, fixture.route.calls).IsEqualTo(0)
+}
+
+func TestConcurrencyLimitMiddlewareReleasesOnPanic(t *testing.T) {
+	// Arrange.
+	fixture := SetupConcurrencyLimitMiddlewareFixture()
+	fixture.route.panic = true
+	fixture.get()
+	fixture.route.panic = false
+
+	// Act.
+	res := fixture.get()
+
+	// Assert.
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusNoContent)
+	test.That(t, len(fixture.x.slots)).IsEqualTo(0)
+}
+
+// -----------------------------------------------------------------------------
+
+type testConcurrencyLimitedRoute struct {
+	middleware Middleware
+	calls      int
+	panic      bool
+}
+
+var _ Route = &testConcurrencyLimitedRoute{}
+
+func (*testConcurrencyLimitedRoute) Method() string {
+	return http.MethodGet
+}
+
+func (*testConcurrencyLimitedRoute) Path() string {
+	return "/limited"
+}
+
+func (route *testConcurrencyLimitedRoute) Middleware() []Middleware {
+	return []Middleware{route.middleware}
+}
+
+func (route *testConcurrencyLimitedRoute) Handle(ctx *Context) {
+	route.calls++
+
+	if route.panic {
+		panic("something to panic about")
+	}
+
+	ctx.Respond(http.StatusNoContent)
+}
@@ -1,9 +1,53 @@
 package web
 
+import (
+	"html/template"
+	"net/http"
+	"time"
+)
+
 // Config defines a set of configuration values that dictate how the handler
 // behaves at a global level.
 type Config struct {
-	ProblemDetailsTypePrefix string
-	DebuggingEnabled         bool
-	JSONContentLengthLimit   int64
+	ProblemDetailsTypePrefix    string
+	DebuggingEnabled            bool
+	JSONContentLengthLimit      int64
+	StrictSlash                 bool
+	RedirectTrailingSlash       bool
+	TrustedProxies              []string
+	DefaultJSONContentType      string
+	MaxJSONDepth                int
+	JSONSchemaValidator         JSONSchemaValidatorFunc
+	CorrelationIDHeader         string
+	PropagatedHeaders           []string
+	RouteConflictHandler        func(conflict Conflict)
+	BasePath                    string
+	OnPanic                     func(ctx *Context, err error)
+	ProblemDetailsInstance      bool
+	SuppressCorrelationIDHeader bool
+	AllowMethodOverride         bool
+	ContentLengthLimits         map[string]int64
+	DisablePanicRecovery        bool
+	EmitServerTiming            bool
+	ErrorSanitizer              func(err error) string
+	AutoHead                    bool
+	ProblemTitles               map[int]string
+	ProblemSlugs                map[int]string
+	MaxHeaderCount              int
+	DefaultHeaders              http.Header
+	CSVByteOrderMark            bool
+	HTMLErrorTemplate           *template.Template
+	DurationUnit                time.Duration
 }
+
+// DefaultCorrelationIDHeader is the name of the header used to communicate the
+// correlation ID of a request when Config.CorrelationIDHeader is not set.
+const DefaultCorrelationIDHeader = "Correlation-ID"
+
+// DefaultMaxJSONDepth is the maximum nesting depth of JSON request bodies that
+// is used when Config.MaxJSONDepth is not set.
+const DefaultMaxJSONDepth = 64
+
+// DefaultJSONContentLengthLimit is the maximum length of request bodies that is
+// used when Config.JSONContentLengthLimit is not set.
+const DefaultJSONContentLengthLimit = 1 << 20
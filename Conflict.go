@@ -0,0 +1,23 @@
+package web
+
+import "fmt"
+
+// Conflict describes two route path templates that can match the same request
+// path.  Routes are registered most-specific first, so the Preferred template
+// takes precedence and the Shadowed template will never receive requests that
+// match both.  Host is empty for routes that are not registered through a
+// builder returned by HandlerBuilder.Host.
+type Conflict struct {
+	Host      string
+	Preferred string
+	Shadowed  string
+}
+
+// String returns a human-readable description of the conflict.
+func (c Conflict) String() string {
+	if c.Host == "" {
+		return fmt.Sprintf("the path '%v' shadows the path '%v'", c.Preferred, c.Shadowed)
+	}
+
+	return fmt.Sprintf("the path '%v' shadows the path '%v' on the host '%v'", c.Preferred, c.Shadowed, c.Host)
+}
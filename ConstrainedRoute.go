@@ -0,0 +1,11 @@
+package web
+
+// ConstrainedRoute is an optional interface that a Route can implement to
+// declare regular expression constraints for its path parameters.  The keys of
+// the map returned by Constraints are path parameter names, and the values are
+// the regular expressions that those parameters must match.  Requests that do
+// not satisfy the constraints will not match the route.
+type ConstrainedRoute interface {
+	Route
+	Constraints() map[string]string
+}
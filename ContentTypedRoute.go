@@ -0,0 +1,10 @@
+package web
+
+// ContentTypedRoute is an optional interface that a Route can implement to
+// declare the request content types it accepts.  The HandlerBuilder asserts the
+// content type of the request with Context.AssertContentType after the
+// middleware for the route have run, and before the route is handled.
+type ContentTypedRoute interface {
+	Route
+	Accepts() []string
+}
@@ -1,17 +1,49 @@
 package web
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/ljpx/di"
 	"github.com/ljpx/id"
+	"github.com/ljpx/logging"
 	"github.com/ljpx/problem"
 )
 
+// maxPooledJSONBufferSize is the largest capacity of a JSON buffer that is
+// returned to jsonBufferPool, so that one large response does not pin a buffer
+// of that size in memory for the life of the process.
+const maxPooledJSONBufferSize = 64 << 10
+
+var jsonBufferPool = sync.Pool{
+	New: func() interface{} {
+		return &bytes.Buffer{}
+	},
+}
+
+// StreamFlushInterval is the number of records an NDJSONWriter, or elements a
+// JSONArrayWriter, writes between each flush of the response.
+const StreamFlushInterval = 32
+
 // Context represents the context of a single HTTP web request.  It is not
 // thread-safe.
 type Context struct {
@@ -19,14 +51,39 @@ type Context struct {
 	r      *http.Request
 	c      di.Container
 	config *Config
+	logger logging.Logger
 
-	correlationID       id.ID
-	middlewareArtifacts map[string]interface{}
+	correlationID        id.ID
+	middlewareArtifacts  map[string]interface{}
+	rawBody              []byte
+	hasReadRawBody       bool
+	rawBodyFailed        bool
+	multipartFormFailed  bool
+	mrw                  *MeasuredResponseWriter
+	brw                  *BufferingResponseWriter
+	responseTransformers []func(status int, body []byte) []byte
+	completionHooks      []func()
+	responded            bool
+	statusCode           int
+	startTime            time.Time
 }
 
-// NewContext creates a new context for the provided request.
+// NewContext creates a new context for the provided request.  If the provided
+// container is nil, an empty container is used in its place.  The container is
+// forked for the request, and a ContextProvider for the new context is
+// registered into the fork.
 func NewContext(w http.ResponseWriter, r *http.Request, c di.Container, config *Config) *Context {
-	return &Context{
+	if c == nil {
+		c = di.NewContainer()
+	}
+
+	startTime := time.Now()
+	mrw, ok := w.(*MeasuredResponseWriter)
+	if ok {
+		startTime = mrw.StartTime()
+	}
+
+	ctx := &Context{
 		w:      w,
 		r:      r,
 		c:      c.Fork(),
@@ -34,7 +91,15 @@ func NewContext(w http.ResponseWriter, r *http.Request, c di.Container, config *
 
 		correlationID:       id.New(),
 		middlewareArtifacts: make(map[string]interface{}),
+		mrw:                 mrw,
+		startTime:           startTime,
 	}
+
+	ctx.c.Register(di.InstancePerContainer, func(c di.Container) (ContextProvider, error) {
+		return &contextProvider{ctx: ctx}, nil
+	})
+
+	return ctx
 }
 
 // GetCorrelationID returns the correlationID for the request.
@@ -42,6 +107,30 @@ func (ctx *Context) GetCorrelationID() id.ID {
 	return ctx.correlationID
 }
 
+// PropagateHeaders copies the correlation ID of the request, along with any
+// request headers listed in Config.PropagatedHeaders, onto the provided
+// outbound request.
+func (ctx *Context) PropagateHeaders(req *http.Request) {
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+
+	req.Header.Set(ctx.correlationIDHeader(), ctx.correlationID.String())
+
+	for _, name := range ctx.config.PropagatedHeaders {
+		if values, ok := ctx.r.Header[http.CanonicalHeaderKey(name)]; ok {
+			req.Header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
+	}
+}
+
+// StartTime returns the time at which the request started.  If the Context was
+// created with a MeasuredResponseWriter, this is the start time it recorded.
+// Otherwise, it is the time at which the Context was created.
+func (ctx *Context) StartTime() time.Time {
+	return ctx.startTime
+}
+
 // GetMiddlewareArtifact retrieves the middleware artifact with the specified
 // name.  It will return nil if the artifact does not exist.
 func (ctx *Context) GetMiddlewareArtifact(name string) interface{} {
@@ -59,6 +148,66 @@ func (ctx *Context) ResponseWriter() http.ResponseWriter {
 	return ctx.w
 }
 
+// BufferResponse causes the response to be captured in memory rather than
+// written directly, and returns the buffer.  The buffered response is written
+// once the route handler and all middleware After hooks have completed, which
+// allows middleware to inspect and modify the response before it is sent.
+// Calling BufferResponse more than once returns the same buffer.
+func (ctx *Context) BufferResponse() *BufferingResponseWriter {
+	if ctx.brw == nil {
+		ctx.brw = NewBufferingResponseWriter(ctx.w)
+		ctx.w = ctx.brw
+	}
+
+	return ctx.brw
+}
+
+// AddResponseTransformer buffers the response, as with BufferResponse, and
+// registers a function that transforms the buffered body before it is sent.
+// Transformers are called in the order in which they were added, each
+// receiving the body returned by the last, and the Content-Length of the
+// response is updated to match the final body.  Transformers are not called if
+// the buffered response is discarded, such as when the handler panics.
+func (ctx *Context) AddResponseTransformer(fn func(status int, body []byte) []byte) {
+	ctx.BufferResponse()
+	ctx.responseTransformers = append(ctx.responseTransformers, fn)
+}
+
+// ResponseWritten returns true if a response has been started, either directly
+// or into the response buffer.  If the Context was not created with a
+// MeasuredResponseWriter, only responses sent through the methods of Context are
+// detected.
+func (ctx *Context) ResponseWritten() bool {
+	if ctx.brw != nil && !ctx.brw.HasCommitted() {
+		return ctx.brw.statusCode != 0 || ctx.brw.body.Len() > 0
+	}
+
+	if ctx.mrw == nil {
+		return ctx.responded
+	}
+
+	return ctx.mrw.HasWrittenHeaders()
+}
+
+// StatusCode returns the status code of the response, or http.StatusOK if no
+// status code has been written yet.  If the response is being buffered, the
+// buffered status code is returned.
+func (ctx *Context) StatusCode() int {
+	if ctx.brw != nil && !ctx.brw.HasCommitted() {
+		return ctx.brw.StatusCode()
+	}
+
+	if ctx.mrw != nil {
+		return ctx.mrw.StatusCode()
+	}
+
+	if ctx.statusCode == 0 {
+		return http.StatusOK
+	}
+
+	return ctx.statusCode
+}
+
 // Container returns the underlying container.
 func (ctx *Context) Container() di.Container {
 	return ctx.c
@@ -69,11 +218,34 @@ func (ctx *Context) Request() *http.Request {
 	return ctx.r
 }
 
+// Method returns the method of the request.  If the method was overridden with
+// the X-HTTP-Method-Override header, the overriding method is returned.
+func (ctx *Context) Method() string {
+	return ctx.r.Method
+}
+
+// Path returns the path of the request URL.
+func (ctx *Context) Path() string {
+	return ctx.r.URL.Path
+}
+
 // Header returns the set of response headers.
 func (ctx *Context) Header() http.Header {
 	return ctx.w.Header()
 }
 
+// SetHeader sets a response header.  Headers set after a response has been
+// written would be ignored, so SetHeader returns false without setting the
+// header if a response has already been written.
+func (ctx *Context) SetHeader(key string, value string) bool {
+	if ctx.ResponseWritten() {
+		return false
+	}
+
+	ctx.w.Header().Set(key, value)
+	return true
+}
+
 // GetPathParameter retrieves a path segment parameter from the request.
 func (ctx *Context) GetPathParameter(name string) string {
 	val, _ := mux.Vars(ctx.r)[name]
@@ -85,240 +257,1553 @@ func (ctx *Context) GetQueryParameter(name string) string {
 	return ctx.r.URL.Query().Get(name)
 }
 
+// GetQueryParameters retrieves every value of a query parameter from the
+// request, or nil if the parameter is not present.
+func (ctx *Context) GetQueryParameters(name string) []string {
+	return ctx.r.URL.Query()[name]
+}
+
+// ClientIP returns the IP address of the client that made the request.  If the
+// request was received from a trusted proxy, the X-Forwarded-For header is
+// consulted, skipping over any addresses that are themselves trusted proxies.
+func (ctx *Context) ClientIP() string {
+	clientIP := remoteAddrHost(ctx.r.RemoteAddr)
+	if !isTrustedProxy(net.ParseIP(clientIP), ctx.config.TrustedProxies) {
+		return clientIP
+	}
+
+	forwardedFor := strings.Split(ctx.r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwardedFor) - 1; i >= 0; i-- {
+		forwardedIP := strings.TrimSpace(forwardedFor[i])
+		if forwardedIP == "" {
+			continue
+		}
+
+		clientIP = forwardedIP
+		if !isTrustedProxy(net.ParseIP(forwardedIP), ctx.config.TrustedProxies) {
+			break
+		}
+	}
+
+	return clientIP
+}
+
+// AbsoluteURL builds an absolute URL for the provided path using the scheme
+// and host of the request.  If the request was received from a trusted proxy,
+// the X-Forwarded-Proto and X-Forwarded-Host headers are respected.
+func (ctx *Context) AbsoluteURL(path string) string {
+	scheme := "http"
+	if ctx.r.TLS != nil {
+		scheme = "https"
+	}
+
+	host := ctx.r.Host
+
+	if ctx.isFromTrustedProxy() {
+		if forwardedProto := firstHeaderValue(ctx.r.Header, "X-Forwarded-Proto"); forwardedProto != "" {
+			scheme = strings.ToLower(forwardedProto)
+		}
+
+		if forwardedHost := firstHeaderValue(ctx.r.Header, "X-Forwarded-Host"); forwardedHost != "" {
+			host = forwardedHost
+		}
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return fmt.Sprintf("%v://%v%v", scheme, host, path)
+}
+
+// RawBody reads and returns the raw request body, up to the content length
+// limit for the Content-Type of the request.  If the body exceeds the limit, a
+// RequestEntityTooLarge response is sent and false is returned.  The body is
+// cached, so RawBody can be called multiple times, and can be followed by a
+// call to FromJSON.  Once read, the body of the underlying request is replaced
+// with a reader over the cached body.  If reading the body fails, subsequent
+// calls return false without reading or responding again.  If the context of
+// the request has a deadline and it passes before the body has been read, a
+// RequestTimeout response is sent and false is returned.
+func (ctx *Context) RawBody() ([]byte, bool) {
+	if ctx.rawBodyFailed {
+		return nil, false
+	}
+
+	if ctx.hasReadRawBody {
+		return ctx.rawBody, true
+	}
+
+	max := ctx.contentLengthLimit()
+	rawBody, err := ctx.readBodyWithinDeadline(max)
+	if err == context.DeadlineExceeded {
+		ctx.rawBodyFailed = true
+		problem := ctx.getProblemDetailsForRequestTimeout("The request body was not received before the deadline of the request.")
+		ctx.respondWithProblem(http.StatusRequestTimeout, problem)
+		return nil, false
+	} else if err != nil && int64(len(rawBody)) >= max {
+		ctx.rawBodyFailed = true
+		problem := ctx.getProblemDetailsForRequestEntityTooLargeWithUnknownLength(max)
+		ctx.respondWithProblem(http.StatusRequestEntityTooLarge, problem)
+		return nil, false
+	} else if err != nil {
+		ctx.rawBodyFailed = true
+		problem := ctx.getProblemDetailsForUnreadableBody(err)
+		ctx.respondWithProblem(http.StatusBadRequest, problem)
+		return nil, false
+	}
+
+	ctx.rawBody = rawBody
+	ctx.hasReadRawBody = true
+	ctx.r.Body = ioutil.NopCloser(bytes.NewReader(rawBody))
+
+	return rawBody, true
+}
+
+// VerifyHMAC verifies that the named request header contains the hex-encoded
+// HMAC-SHA256 of the raw request body, computed with the provided secret.  The
+// signature may optionally be prefixed with "sha256=".  If the signature is
+// missing or does not match, an Unauthorized response is sent and false is
+// returned.  The raw body is cached, so VerifyHMAC can be followed by a call
+// to FromJSON.
+func (ctx *Context) VerifyHMAC(header string, secret []byte) bool {
+	rawBody, ok := ctx.RawBody()
+	if !ok {
+		return false
+	}
+
+	signature := strings.TrimPrefix(strings.TrimSpace(ctx.r.Header.Get(header)), "sha256=")
+	providedMAC, err := hex.DecodeString(signature)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(rawBody)
+	expectedMAC := mac.Sum(nil)
+
+	if err != nil || !hmac.Equal(providedMAC, expectedMAC) {
+		problem := ctx.getProblemDetailsForInvalidSignature(header)
+		ctx.respondWithProblem(http.StatusUnauthorized, problem)
+		return false
+	}
+
+	return true
+}
+
 // FromJSON retrieves JSON from the request body to place into the provided
-// Purifiable.
+// Purifiable.  Purifiable values nested within the fields and slices of the
+// model are also purified, and every invalid field is reported with its path,
+// such as "items[2].sku".  The request must have a Content-Type of
+// application/json, or of a media type with the +json suffix, such as
+// application/vnd.acme+json.
 func (ctx *Context) FromJSON(model Purifiable) bool {
-	if !ctx.AssertContentType("application/json") {
+	contentType := ctx.r.Header.Get("Content-Type")
+	if !isJSONMediaType(mediaType(contentType)) {
+		ctx.UnsupportedMediaType(contentType, "application/json", "application/*+json")
 		return false
 	}
 
-	if !ctx.AssertContentLength(ctx.config.JSONContentLengthLimit) {
+	rawJSON, ok := ctx.readJSONBody()
+	if !ok {
 		return false
 	}
 
-	decoder := json.NewDecoder(ctx.r.Body)
+	if schemaValidatable, ok := model.(SchemaValidatable); ok {
+		if !ctx.validateJSONSchema(schemaValidatable.Schema(), rawJSON) {
+			return false
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(rawJSON))
 	err := decoder.Decode(model)
-	if err != nil {
+	if err == io.EOF {
+		problem := ctx.getProblemDetailsForEmptyBody()
+		ctx.respondWithProblem(http.StatusBadRequest, problem)
+		return false
+	} else if err != nil {
 		problem := ctx.getProblemDetailsForDeserialization(err)
-		ctx.RespondWithJSON(http.StatusBadRequest, problem)
+		ctx.respondWithProblem(http.StatusBadRequest, problem)
 		return false
 	}
 
-	field, err := model.Purify()
-	if err != nil {
-		problem := ctx.getProblemDetailsForUnprocessableEntity(field, err)
-		ctx.RespondWithJSON(http.StatusUnprocessableEntity, problem)
+	invalidFields := purify(model)
+	if len(invalidFields) > 0 {
+		problem := ctx.getProblemDetailsForUnprocessableEntity(invalidFields)
+		ctx.respondWithProblem(http.StatusUnprocessableEntity, problem)
 		return false
 	}
 
 	return true
 }
 
-// Respond reponds to the request with the provided HTTP code.
-func (ctx *Context) Respond(code int) {
-	ctx.w.Header().Set("Correlation-ID", ctx.correlationID.String())
-	ctx.w.WriteHeader(code)
-}
+// FromJSONMergePatch retrieves a JSON Merge Patch (RFC 7396) from the request
+// body.  The request must have a Content-Type of application/merge-patch+json,
+// and the patch must be a JSON object.  If current is not nil, it must be a
+// pointer to the resource being patched - the patch is applied to it and, if
+// it is Purifiable, it is purified.  The parsed patch is returned so that the
+// handler can inspect which fields were changed.
+func (ctx *Context) FromJSONMergePatch(current interface{}) (map[string]interface{}, bool) {
+	if !ctx.AssertContentType("application/merge-patch+json") {
+		return nil, false
+	}
 
-// RespondWithJSON responds to the request with the provided HTTP code and
-// model.
-func (ctx *Context) RespondWithJSON(code int, model interface{}) {
-	rawJSON, err := json.Marshal(model)
-	if err != nil {
-		rawJSON = ctx.getRawProblemDetailsForSerializationError(err)
-		code = http.StatusInternalServerError
+	rawJSON, ok := ctx.readJSONBody()
+	if !ok {
+		return nil, false
 	}
 
-	ctx.w.Header().Set("Content-Type", "application/json")
-	ctx.w.Header().Set("Content-Length", fmt.Sprintf("%v", len(rawJSON)))
-	ctx.Respond(code)
-	ctx.w.Write([]byte(rawJSON))
-}
+	var patch interface{}
+	decoder := json.NewDecoder(bytes.NewReader(rawJSON))
+	decoder.UseNumber()
+	err := decoder.Decode(&patch)
+	if err == io.EOF {
+		problem := ctx.getProblemDetailsForEmptyBody()
+		ctx.respondWithProblem(http.StatusBadRequest, problem)
+		return nil, false
+	} else if err != nil {
+		problem := ctx.getProblemDetailsForDeserialization(err)
+		ctx.respondWithProblem(http.StatusBadRequest, problem)
+		return nil, false
+	}
 
-// NotFound responds to the request with a NotFound status code.
-func (ctx *Context) NotFound(subjectType string, subject string) {
-	problem := ctx.getProblemDetailsForNotFound(subjectType, subject)
-	ctx.RespondWithJSON(http.StatusNotFound, problem)
-}
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		problem := ctx.getProblemDetailsForMergePatchNotObject()
+		ctx.respondWithProblem(http.StatusBadRequest, problem)
+		return nil, false
+	}
 
-// InternalServerError responds to the request with an InternalServerError
-// status code.
-func (ctx *Context) InternalServerError(err error) {
-	problem := ctx.getProblemDetailsForInternalServerError(err)
-	ctx.RespondWithJSON(http.StatusInternalServerError, problem)
+	if current == nil {
+		return patchObject, true
+	}
+
+	err = applyMergePatch(current, patchObject)
+	if _, ok := err.(*json.UnmarshalTypeError); ok {
+		problem := ctx.getProblemDetailsForDeserialization(err)
+		ctx.respondWithProblem(http.StatusBadRequest, problem)
+		return nil, false
+	} else if err != nil {
+		ctx.InternalServerError(err)
+		return nil, false
+	}
+
+	if purifiable, ok := current.(Purifiable); ok {
+		field, err := purifiable.Purify()
+		if err != nil {
+			problem := ctx.getProblemDetailsForUnprocessableEntity([]invalidField{{field: field, err: err}})
+			ctx.respondWithProblem(http.StatusUnprocessableEntity, problem)
+			return nil, false
+		}
+	}
+
+	return patchObject, true
 }
 
-// Resolve resolves from the underlying container.  It will return false if
-// an error prevented the operation from completing.
-func (ctx *Context) Resolve(dependencies ...interface{}) bool {
-	err := ctx.c.Resolve(dependencies...)
-	if err != nil {
+// QueryInto decodes the query string of the request into the provided
+// Purifiable.  Fields are bound using their query tag, or their url tag if no
+// query tag is present.  Strings, booleans, integers, floats, pointers to those
+// types, and slices of those types (for repeated parameters) are supported.
+func (ctx *Context) QueryInto(model Purifiable) bool {
+	query := ctx.r.URL.Query()
+	err := bindStruct(model, []string{"query", "url"}, func(name string) []string {
+		return query[name]
+	})
+
+	if bindingErr, ok := err.(*fieldBindingError); ok {
+		problem := ctx.getProblemDetailsForInvalidQueryParameter(bindingErr)
+		ctx.respondWithProblem(http.StatusBadRequest, problem)
+		return false
+	} else if err != nil {
 		ctx.InternalServerError(err)
 		return false
 	}
 
+	field, err := model.Purify()
+	if err != nil {
+		problem := ctx.getProblemDetailsForUnprocessableEntity([]invalidField{{field: field, err: err}})
+		ctx.respondWithProblem(http.StatusUnprocessableEntity, problem)
+		return false
+	}
+
 	return true
 }
 
-// AssertContentType ensures that the content type of the request matches one of
-// the content types provided.
-func (ctx *Context) AssertContentType(allowedContentTypes ...string) bool {
-	contentType := ctx.r.Header.Get("Content-Type")
-	contentTypeUppercase := strings.TrimSpace(strings.ToUpper(contentType))
+// FormFile retrieves the first file with the provided name from a
+// multipart/form-data request body, up to the content length limit for
+// multipart/form-data.  If the body is malformed or the file is missing, a
+// BadRequest response is sent and false is returned.  The caller is
+// responsible for closing the returned file.
+func (ctx *Context) FormFile(name string) (multipart.File, *multipart.FileHeader, bool) {
+	if !ctx.parseMultipartForm() {
+		return nil, nil, false
+	}
 
-	for _, allowedContentType := range allowedContentTypes {
-		if contentTypeUppercase == strings.ToUpper(allowedContentType) {
-			return true
-		}
+	fileHeaders := ctx.r.MultipartForm.File[name]
+	if len(fileHeaders) == 0 {
+		problem := ctx.getProblemDetailsForMissingFormFile(name)
+		ctx.respondWithProblem(http.StatusBadRequest, problem)
+		return nil, nil, false
 	}
 
-	problem := ctx.getProblemDetailsForUnsupportedMediaType(contentType, allowedContentTypes)
-	ctx.RespondWithJSON(http.StatusUnsupportedMediaType, problem)
+	file, err := fileHeaders[0].Open()
+	if err != nil {
+		ctx.InternalServerError(err)
+		return nil, nil, false
+	}
 
-	return false
+	return file, fileHeaders[0], true
 }
 
-// AssertContentLength ensures that a content length was provided, and that it
-// is in (0, max].
-func (ctx *Context) AssertContentLength(max int64) bool {
-	contentLength := ctx.r.ContentLength
+// Pagination reads the page and pageSize query parameters of the request.  The
+// page defaults to 1 and is at least 1.  The page size defaults to defaultSize
+// and is clamped to between 1 and maxSize.  If either parameter is not an
+// integer, a BadRequest response is sent and false is returned.
+func (ctx *Context) Pagination(defaultSize int, maxSize int) (int, int, bool) {
+	page, ok := ctx.intQueryParameter("page", 1)
+	if !ok {
+		return 0, 0, false
+	}
 
-	if contentLength > max {
-		problem := ctx.getProblemDetailsForRequestEntityTooLarge(contentLength, max)
-		ctx.RespondWithJSON(http.StatusRequestEntityTooLarge, problem)
-		return false
+	size, ok := ctx.intQueryParameter("pageSize", defaultSize)
+	if !ok {
+		return 0, 0, false
 	}
 
-	if contentLength <= 0 {
-		problem := ctx.getProblemDetailsForLengthRequired()
-		ctx.RespondWithJSON(http.StatusLengthRequired, problem)
+	if page < 1 {
+		page = 1
+	}
+
+	if size > maxSize {
+		size = maxSize
+	}
+
+	if size < 1 {
+		size = 1
+	}
+
+	return page, size, true
+}
+
+// HeadersInto maps the headers of the request into the fields of the provided
+// model using their header tag.  The same types as QueryInto are supported.
+func (ctx *Context) HeadersInto(model interface{}) bool {
+	err := bindStruct(model, []string{"header"}, func(name string) []string {
+		return ctx.r.Header[http.CanonicalHeaderKey(name)]
+	})
+
+	if bindingErr, ok := err.(*fieldBindingError); ok {
+		problem := ctx.getProblemDetailsForInvalidHeader(bindingErr)
+		ctx.respondWithProblem(http.StatusBadRequest, problem)
+		return false
+	} else if err != nil {
+		ctx.InternalServerError(err)
 		return false
 	}
 
 	return true
 }
 
-// AssertMethod ensures that the incoming request is using one of the provided
-// methods.
-func (ctx *Context) AssertMethod(allowedMethods ...string) bool {
-	methodUpperCase := strings.ToUpper(ctx.r.Method)
+// Respond reponds to the request with the provided HTTP code.  Unless
+// Config.SuppressCorrelationIDHeader is set, the correlation ID of the request is
+// included in the response headers.  Headers must be set, for example with
+// SetHeader, before calling Respond.  Calling Respond after a response has been
+// written has no effect.
+func (ctx *Context) Respond(code int) {
+	if ctx.ResponseWritten() {
+		return
+	}
 
-	for _, allowedMethod := range allowedMethods {
-		if methodUpperCase == strings.ToUpper(allowedMethod) {
-			return true
-		}
+	if !ctx.config.SuppressCorrelationIDHeader {
+		ctx.w.Header().Set(ctx.correlationIDHeader(), ctx.correlationID.String())
 	}
 
-	problem := ctx.getProblemDetailsForMethodNotAllowed(ctx.r.Method, allowedMethods)
-	ctx.RespondWithJSON(http.StatusMethodNotAllowed, problem)
+	ctx.w.WriteHeader(code)
 
-	return false
+	if !ctx.responded {
+		ctx.responded = true
+		ctx.statusCode = code
+	}
 }
 
-func (ctx *Context) getProblemDetailsForUnsupportedMediaType(providedContentType string, allowedContentTypes []string) *problem.Details {
-	return &problem.Details{
-		Type:   fmt.Sprintf("%v/http/unsupported-media-type", ctx.config.ProblemDetailsTypePrefix),
-		Title:  "Unsupported Media Type",
-		Detail: fmt.Sprintf("The Content-Type '%v' is not supported by this endpoint.", providedContentType),
-		Specifics: map[string]interface{}{
-			"providedContentType": providedContentType,
-			"allowedContentTypes": allowedContentTypes,
-		},
+// Redirect responds to the request with the provided redirection status code,
+// such as http.StatusSeeOther, and a Location header of the provided location.
+// Unlike http.Redirect, no body is written.
+func (ctx *Context) Redirect(location string, code int) {
+	if ctx.ResponseWritten() {
+		return
 	}
+
+	ctx.w.Header().Set("Location", location)
+	ctx.setContentLength(0)
+	ctx.Respond(code)
 }
 
-func (ctx *Context) getProblemDetailsForRequestEntityTooLarge(contentLength, max int64) *problem.Details {
-	detailFormat := "The provided request entity of length %v (%v bytes) exceeds the maximum of %v (%v bytes) on this endpoint."
-	return &problem.Details{
-		Type:   fmt.Sprintf("%v/http/request-entity-too-large", ctx.config.ProblemDetailsTypePrefix),
-		Title:  "Request Entity Too Large",
-		Detail: fmt.Sprintf(detailFormat, ByteSizeToFriendlyString(contentLength), contentLength, ByteSizeToFriendlyString(max), max),
-		Specifics: map[string]interface{}{
-			"contentLength":        contentLength,
-			"maximumContentLength": max,
-		},
+// RespondWithJSON responds to the request with the provided HTTP code and
+// model.  The Content-Type of the response is Config.DefaultJSONContentType,
+// or application/json if it is not set.
+func (ctx *Context) RespondWithJSON(code int, model interface{}) {
+	contentType := ctx.config.DefaultJSONContentType
+	if contentType == "" {
+		contentType = "application/json"
 	}
+
+	ctx.respondWithJSONContentType(code, model, contentType)
 }
 
-func (ctx *Context) getProblemDetailsForLengthRequired() *problem.Details {
-	return &problem.Details{
-		Type:   fmt.Sprintf("%v/http/length-required", ctx.config.ProblemDetailsTypePrefix),
-		Title:  "Length Required",
-		Detail: "This endpoint requires that the Content-Length header be set to a positive, non-zero value.",
+// RespondWithRawJSON responds to the request with the provided HTTP code and
+// pre-serialized JSON, which is written as-is.  The Content-Type of the
+// response is the same as for RespondWithJSON.  When debugging is enabled, the
+// JSON is validated, and an InternalServerError is sent if it is invalid.
+func (ctx *Context) RespondWithRawJSON(code int, raw []byte) {
+	if ctx.ResponseWritten() {
+		return
 	}
-}
 
-func (ctx *Context) getProblemDetailsForMethodNotAllowed(method string, allowedMethods []string) *problem.Details {
-	return &problem.Details{
-		Type:   fmt.Sprintf("%v/http/method-not-allowed", ctx.config.ProblemDetailsTypePrefix),
-		Title:  "Method Not Allowed",
-		Detail: fmt.Sprintf(`This endpoint does not allow use of the '%v' method.`, method),
-		Specifics: map[string]interface{}{
-			"methodUsed":     method,
-			"allowedMethods": allowedMethods,
-		},
+	if ctx.config.DebuggingEnabled && !json.Valid(raw) {
+		ctx.InternalServerError(fmt.Errorf("the raw JSON response is not valid JSON"))
+		return
+	}
+
+	contentType := ctx.config.DefaultJSONContentType
+	if contentType == "" {
+		contentType = "application/json"
 	}
+
+	ctx.w.Header().Set("Content-Type", contentType)
+	ctx.setContentLength(len(raw))
+	ctx.Respond(code)
+	ctx.w.Write(raw)
 }
 
-func (ctx *Context) getProblemDetailsForDeserialization(err error) *problem.Details {
-	problem := &problem.Details{
-		Type:   fmt.Sprintf("%v/json/deserialization", ctx.config.ProblemDetailsTypePrefix),
-		Title:  "Deserialization Error",
-		Detail: "The provided request body could not be meaningfully deserialized.  It appears to be invalid.",
+// RespondWithPage responds to the request with the provided HTTP code and a
+// Page containing the provided data.  Link headers for the next and previous
+// pages are set where they exist, using the URL of the request with updated
+// page and pageSize query parameters.
+func (ctx *Context) RespondWithPage(code int, data interface{}, page int, pageSize int, total int) {
+	links := []string{}
+	if page > 1 {
+		links = append(links, fmt.Sprintf(`<%v>; rel="prev"`, ctx.pageURL(page-1, pageSize)))
 	}
 
-	if ctx.config.DebuggingEnabled {
-		problem.AttachError(err)
+	if page*pageSize < total {
+		links = append(links, fmt.Sprintf(`<%v>; rel="next"`, ctx.pageURL(page+1, pageSize)))
 	}
 
-	return problem
+	if len(links) > 0 {
+		ctx.w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	ctx.RespondWithJSON(code, &Page{
+		Data:     data,
+		Page:     page,
+		PageSize: pageSize,
+		Total:    total,
+	})
 }
 
-func (ctx *Context) getProblemDetailsForUnprocessableEntity(field string, err error) *problem.Details {
-	return &problem.Details{
-		Type:   fmt.Sprintf("%v/http/unprocessable-entity", ctx.config.ProblemDetailsTypePrefix),
-		Title:  "Unprocessable Entity",
-		Detail: fmt.Sprintf(`The provided request body was understood but contained some invalid values.`),
-		Specifics: map[string]interface{}{
-			"field": field,
-			"error": err.Error(),
-		},
+// Accepted responds to the request with an Accepted status code and a Location
+// header pointing at the provided status resource.  If model is not nil, it is
+// written as the JSON body of the response.
+func (ctx *Context) Accepted(statusLocation string, model interface{}) {
+	ctx.w.Header().Set("Location", statusLocation)
+
+	if model == nil {
+		ctx.Respond(http.StatusAccepted)
+		return
 	}
+
+	ctx.RespondWithJSON(http.StatusAccepted, model)
 }
 
-func (ctx *Context) getProblemDetailsForNotFound(subjectType string, subject string) *problem.Details {
-	return &problem.Details{
-		Type:   fmt.Sprintf("%v/http/not-found", ctx.config.ProblemDetailsTypePrefix),
-		Title:  "Not Found",
-		Detail: fmt.Sprintf(`The %v '%v' was not found.`, subjectType, subject),
-		Specifics: map[string]interface{}{
-			"subjectType": subjectType,
-			"subject":     subject,
-		},
+// Download responds to the request with the provided content as an attachment
+// with the provided filename.  If contentType is empty, it is detected from the
+// extension of the filename, falling back to application/octet-stream.
+// Filenames containing characters outside of printable ASCII are encoded as
+// described by RFC 6266.  An error is returned if a response has already been
+// written.
+func (ctx *Context) Download(filename string, contentType string, content io.Reader) error {
+	if ctx.ResponseWritten() {
+		return fmt.Errorf("a response has already been written")
 	}
-}
 
-func (ctx *Context) getProblemDetailsForInternalServerError(err error) *problem.Details {
-	problem := &problem.Details{
-		Type:   fmt.Sprintf("%v/http/internal-server-error", ctx.config.ProblemDetailsTypePrefix),
-		Title:  "Internal Server Error",
-		Detail: fmt.Sprintf("An internal server error prevented the request from completing."),
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(filename))
 	}
 
-	if ctx.config.DebuggingEnabled && err != nil {
-		problem.AttachError(err)
+	if contentType == "" {
+		contentType = "application/octet-stream"
 	}
 
-	return problem
+	ctx.w.Header().Set("Content-Type", contentType)
+	ctx.w.Header().Set("Content-Disposition", contentDisposition("attachment", filename))
+	ctx.Respond(http.StatusOK)
+
+	_, err := io.Copy(ctx.w, content)
+	return err
 }
 
-func (ctx *Context) getRawProblemDetailsForSerializationError(err error) []byte {
-	formatJSON := `{"type":"%v/http/internal-server-error","title":"Internal Server Error","detail":"Serialization of the response model failed."%v}`
+// RespondWithCSV responds to the request with the provided HTTP code and a CSV
+// attachment with the provided filename.  If headers is not empty, it is
+// written as the first record.  Rows are written as they are encoded rather
+// than being buffered in full.  If Config.CSVByteOrderMark is set, the body is
+// prefixed with a UTF-8 byte order mark.
+func (ctx *Context) RespondWithCSV(code int, headers []string, rows [][]string, filename string) {
+	if ctx.ResponseWritten() {
+		return
+	}
+
+	ctx.w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	ctx.w.Header().Set("Content-Disposition", contentDisposition("attachment", filename))
+	ctx.Respond(code)
+
+	if ctx.config.CSVByteOrderMark {
+		ctx.w.Write([]byte("\uFEFF"))
+	}
+
+	cw := csv.NewWriter(ctx.w)
+	if len(headers) > 0 {
+		cw.Write(headers)
+	}
+
+	for _, row := range rows {
+		cw.Write(row)
+	}
+
+	cw.Flush()
+}
+
+// StreamNDJSON responds to the request with the provided HTTP code and returns
+// an NDJSONWriter through which newline-delimited JSON records can be written.
+// An error is returned if a response has already been written.
+func (ctx *Context) StreamNDJSON(code int) (*NDJSONWriter, error) {
+	if ctx.ResponseWritten() {
+		return nil, fmt.Errorf("a response has already been written")
+	}
+
+	ctx.w.Header().Set("Content-Type", "application/x-ndjson")
+	ctx.w.Header().Del("Content-Length")
+	ctx.Respond(code)
+
+	return newNDJSONWriter(ctx), nil
+}
+
+// StreamJSONArray responds to the request with the provided HTTP code, writes
+// the opening bracket of a JSON array and returns a JSONArrayWriter through
+// which the elements of the array can be written.  The JSONArrayWriter must be
+// closed to complete the array.  An error is returned if a response has
+// already been written.
+func (ctx *Context) StreamJSONArray(code int) (*JSONArrayWriter, error) {
+	if ctx.ResponseWritten() {
+		return nil, fmt.Errorf("a response has already been written")
+	}
+
+	contentType := ctx.config.DefaultJSONContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	ctx.w.Header().Set("Content-Type", contentType)
+	ctx.w.Header().Del("Content-Length")
+	ctx.Respond(code)
+
+	_, err := ctx.w.Write([]byte("["))
+	if err != nil {
+		return nil, err
+	}
+
+	return newJSONArrayWriter(ctx), nil
+}
+
+// logStreamFailure logs an error that occurred after a streamed response was
+// started, and so could not be reported to the client.
+func logStreamFailure(ctx *Context, err error) {
+	if logger := resolveRequestLogger(ctx, ctx.logger); logger != nil {
+		logger.Printf("! %v %v\n", ctx.r.URL.Path, err)
+	}
+}
+
+// NotFound responds to the request with a NotFound status code.
+func (ctx *Context) NotFound(subjectType string, subject string) {
+	problem := ctx.getProblemDetailsForNotFound(subjectType, subject)
+	ctx.respondWithProblem(http.StatusNotFound, problem)
+}
+
+// Gone responds to the request with a Gone status code.
+func (ctx *Context) Gone(subjectType string, subject string) {
+	problem := ctx.getProblemDetailsForGone(subjectType, subject)
+	ctx.respondWithProblem(http.StatusGone, problem)
+}
+
+// RequestTimeout responds to the request with a RequestTimeout status code and
+// the provided detail.
+func (ctx *Context) RequestTimeout(detail string) {
+	problem := ctx.getProblemDetailsForRequestTimeout(detail)
+	ctx.respondWithProblem(http.StatusRequestTimeout, problem)
+}
+
+// RequestHeaderFieldsTooLarge responds to the request with a
+// RequestHeaderFieldsTooLarge status code and the provided detail.
+func (ctx *Context) RequestHeaderFieldsTooLarge(detail string) {
+	problem := ctx.getProblemDetailsForRequestHeaderFieldsTooLarge(detail)
+	ctx.respondWithProblem(http.StatusRequestHeaderFieldsTooLarge, problem)
+}
+
+// PreconditionFailed responds to the request with a PreconditionFailed status
+// code and the provided detail.
+func (ctx *Context) PreconditionFailed(detail string) {
+	problem := ctx.getProblemDetailsForPreconditionFailed(detail)
+	ctx.respondWithProblem(http.StatusPreconditionFailed, problem)
+}
+
+// PreconditionRequired responds to the request with a PreconditionRequired
+// status code and the provided detail.
+func (ctx *Context) PreconditionRequired(detail string) {
+	problem := ctx.getProblemDetailsForPreconditionRequired(detail)
+	ctx.respondWithProblem(http.StatusPreconditionRequired, problem)
+}
+
+// UnsupportedMediaType responds to the request with an UnsupportedMediaType
+// status code, describing the provided content type and the allowed content
+// types.  The response is identical to the one sent by AssertContentType.
+func (ctx *Context) UnsupportedMediaType(provided string, allowed ...string) {
+	problem := ctx.getProblemDetailsForUnsupportedMediaType(provided, allowed)
+	ctx.respondWithProblem(http.StatusUnsupportedMediaType, problem)
+}
+
+// InternalServerError responds to the request with an InternalServerError
+// status code.
+func (ctx *Context) InternalServerError(err error) {
+	problem := ctx.getProblemDetailsForInternalServerError(err)
+	ctx.respondWithProblem(http.StatusInternalServerError, problem)
+}
+
+// InternalServerErrorWithCode responds to the request with an
+// InternalServerError status code, including the provided code in the
+// specifics of the response.  Unlike the error, which is only included when
+// debugging is enabled, the code is always included, and so must be safe to
+// expose to clients.
+func (ctx *Context) InternalServerErrorWithCode(code string, err error) {
+	problem := ctx.getProblemDetailsForInternalServerError(err)
+	problem.Specifics = map[string]interface{}{
+		"code": code,
+	}
+
+	ctx.respondWithProblem(http.StatusInternalServerError, problem)
+}
+
+// Fail responds to the request with a problem describing the provided error.
+// If the error is, or wraps, an HTTPError, the problem is built from it.
+// Otherwise, Fail behaves like InternalServerError.
+func (ctx *Context) Fail(err error) {
+	httpErr, ok := asHTTPError(err)
+	if !ok {
+		ctx.InternalServerError(err)
+		return
+	}
+
+	ctx.respondWithProblem(httpErr.status(), ctx.getProblemDetailsForHTTPError(httpErr))
+}
+
+// TooManyRequests responds to the request with a TooManyRequests status code.
+// If retryAfter is positive, the Retry-After header is set to the number of
+// seconds the client should wait before retrying.
+func (ctx *Context) TooManyRequests(retryAfter time.Duration) {
+	detail := "Too many requests have been made in a short period of time.  Please try again later."
+	ctx.RespondWithRetryableProblem(http.StatusTooManyRequests, "too-many-requests", detail, retryAfter)
+}
+
+// RespondWithRetryableProblem responds to the request with a problem that the
+// client may retry.  The type of the problem is formed from the provided slug,
+// and the title from the provided HTTP code.  If retryAfter is positive, the
+// Retry-After header is set to the number of seconds the client should wait
+// before retrying.
+func (ctx *Context) RespondWithRetryableProblem(code int, slug string, detail string, retryAfter time.Duration) {
+	retryAfterSeconds := int64(math.Ceil(retryAfter.Seconds()))
+	if retryAfterSeconds > 0 {
+		ctx.w.Header().Set("Retry-After", fmt.Sprintf("%v", retryAfterSeconds))
+	}
+
+	problem := ctx.getProblemDetailsForRetryableProblem(code, slug, detail, retryAfterSeconds)
+	ctx.respondWithProblem(code, problem)
+}
+
+// Resolve resolves from the underlying container.  It will return false if
+// an error prevented the operation from completing.
+func (ctx *Context) Resolve(dependencies ...interface{}) bool {
+	err := ctx.c.Resolve(dependencies...)
+	if err != nil {
+		ctx.InternalServerError(err)
+		return false
+	}
+
+	return true
+}
+
+// AssertContentType ensures that the content type of the request matches one of
+// the content types provided.  Parameters of the content type, such as charset,
+// are ignored, as are case and surrounding whitespace.
+func (ctx *Context) AssertContentType(allowedContentTypes ...string) bool {
+	contentType := ctx.r.Header.Get("Content-Type")
+	requestMediaType := mediaType(contentType)
+
+	for _, allowedContentType := range allowedContentTypes {
+		if strings.EqualFold(requestMediaType, mediaType(allowedContentType)) {
+			return true
+		}
+	}
+
+	ctx.UnsupportedMediaType(contentType, allowedContentTypes...)
+
+	return false
+}
+
+// AssertContentLength ensures that a content length was provided, and that it
+// is in (0, max].
+func (ctx *Context) AssertContentLength(max int64) bool {
+	contentLength := ctx.r.ContentLength
+
+	if contentLength > max {
+		problem := ctx.getProblemDetailsForRequestEntityTooLarge(contentLength, max)
+		ctx.respondWithProblem(http.StatusRequestEntityTooLarge, problem)
+		return false
+	}
+
+	if contentLength <= 0 {
+		problem := ctx.getProblemDetailsForLengthRequired()
+		ctx.respondWithProblem(http.StatusLengthRequired, problem)
+		return false
+	}
+
+	return true
+}
+
+// AssertMethod ensures that the incoming request is using one of the provided
+// methods.  If it is not, the Allow header of the response lists the provided
+// methods.
+func (ctx *Context) AssertMethod(allowedMethods ...string) bool {
+	methodUpperCase := strings.ToUpper(ctx.r.Method)
+
+	for _, allowedMethod := range allowedMethods {
+		if methodUpperCase == strings.ToUpper(allowedMethod) {
+			return true
+		}
+	}
+
+	ctx.w.Header().Set("Allow", strings.Join(allowedMethods, ", "))
+	problem := ctx.getProblemDetailsForMethodNotAllowed(ctx.r.Method, allowedMethods)
+	ctx.respondWithProblem(http.StatusMethodNotAllowed, problem)
+
+	return false
+}
+
+// AssertQueryParameters ensures that all of the provided query parameters are
+// present in the request and are non-empty.
+func (ctx *Context) AssertQueryParameters(names ...string) bool {
+	query := ctx.r.URL.Query()
+	missingQueryParameters := []string{}
+
+	for _, name := range names {
+		if strings.TrimSpace(query.Get(name)) == "" {
+			missingQueryParameters = append(missingQueryParameters, name)
+		}
+	}
+
+	if len(missingQueryParameters) == 0 {
+		return true
+	}
+
+	problem := ctx.getProblemDetailsForMissingQueryParameters(missingQueryParameters)
+	ctx.respondWithProblem(http.StatusBadRequest, problem)
+
+	return false
+}
+
+// AssertHeaders ensures that all of the provided request headers are present
+// in the request and are non-empty.
+func (ctx *Context) AssertHeaders(names ...string) bool {
+	missingHeaders := []string{}
+
+	for _, name := range names {
+		if strings.TrimSpace(ctx.r.Header.Get(name)) == "" {
+			missingHeaders = append(missingHeaders, name)
+		}
+	}
+
+	if len(missingHeaders) == 0 {
+		return true
+	}
+
+	problem := ctx.getProblemDetailsForMissingHeaders(missingHeaders)
+	ctx.respondWithProblem(http.StatusBadRequest, problem)
+
+	return false
+}
+
+// RequireIfMatch returns true if the If-Match header of the request matches the
+// provided current ETag of the resource, using strong comparison.  If the header
+// is absent, a PreconditionRequired problem is sent.  If it does not match, a
+// PreconditionFailed problem is sent.  The current ETag may be provided with or
+// without its surrounding quotes.
+func (ctx *Context) RequireIfMatch(currentETag string) bool {
+	ifMatch := strings.TrimSpace(ctx.r.Header.Get("If-Match"))
+	if ifMatch == "" {
+		ctx.PreconditionRequired("This request requires the If-Match header.")
+		return false
+	}
+
+	if etagListMatches(ifMatch, quoteETag(currentETag)) {
+		return true
+	}
+
+	ctx.PreconditionFailed("The If-Match header does not match the current ETag of the resource.")
+	return false
+}
+
+func (ctx *Context) readJSONBody() ([]byte, bool) {
+	if ctx.r.ContentLength == 0 {
+		problem := ctx.getProblemDetailsForEmptyBody()
+		ctx.respondWithProblem(http.StatusBadRequest, problem)
+		return nil, false
+	}
+
+	if !ctx.AssertContentLength(ctx.contentLengthLimit()) {
+		return nil, false
+	}
+
+	rawJSON, ok := ctx.RawBody()
+	if !ok {
+		return nil, false
+	}
+
+	maxDepth := ctx.config.MaxJSONDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxJSONDepth
+	}
+
+	if jsonDepthExceeds(rawJSON, maxDepth) {
+		problem := ctx.getProblemDetailsForMaximumDepthExceeded(maxDepth)
+		ctx.respondWithProblem(http.StatusBadRequest, problem)
+		return nil, false
+	}
+
+	return rawJSON, true
+}
+
+// contentLengthLimit returns the entry of Config.ContentLengthLimits for the
+// media type of the request, or Config.JSONContentLengthLimit if there is none.
+// If Config.JSONContentLengthLimit is not set, DefaultJSONContentLengthLimit is
+// used.
+func (ctx *Context) contentLengthLimit() int64 {
+	requestMediaType := mediaType(ctx.r.Header.Get("Content-Type"))
+	for contentType, limit := range ctx.config.ContentLengthLimits {
+		if strings.EqualFold(contentType, requestMediaType) {
+			return limit
+		}
+	}
+
+	if ctx.config.JSONContentLengthLimit <= 0 {
+		return DefaultJSONContentLengthLimit
+	}
+
+	return ctx.config.JSONContentLengthLimit
+}
+
+func (ctx *Context) parseMultipartForm() bool {
+	if ctx.multipartFormFailed {
+		return false
+	}
+
+	if ctx.r.MultipartForm != nil {
+		return true
+	}
+
+	if !ctx.AssertContentType("multipart/form-data") {
+		ctx.multipartFormFailed = true
+		return false
+	}
+
+	max := ctx.contentLengthLimit()
+	body := &countingReader{r: ctx.r.Body}
+	ctx.r.Body = http.MaxBytesReader(ctx.w, ioutil.NopCloser(body), max)
+
+	err := ctx.r.ParseMultipartForm(max)
+	if err != nil && body.n > max {
+		ctx.multipartFormFailed = true
+		problem := ctx.getProblemDetailsForRequestEntityTooLargeWithUnknownLength(max)
+		ctx.respondWithProblem(http.StatusRequestEntityTooLarge, problem)
+		return false
+	} else if err != nil {
+		ctx.multipartFormFailed = true
+		problem := ctx.getProblemDetailsForMalformedMultipartBody(err)
+		ctx.respondWithProblem(http.StatusBadRequest, problem)
+		return false
+	}
+
+	return true
+}
+
+// readBodyWithinDeadline reads up to max bytes of the request body.  If the
+// context of the request has a deadline, the read is abandoned when the context
+// is done, and the error of the context is returned.
+func (ctx *Context) readBodyWithinDeadline(max int64) ([]byte, error) {
+	reader := http.MaxBytesReader(ctx.w, ctx.r.Body, max)
+
+	requestContext := ctx.r.Context()
+	if _, ok := requestContext.Deadline(); !ok {
+		return ioutil.ReadAll(reader)
+	}
+
+	type readResult struct {
+		body []byte
+		err  error
+	}
+
+	results := make(chan readResult, 1)
+	go func() {
+		body, err := ioutil.ReadAll(reader)
+		results <- readResult{body: body, err: err}
+	}()
+
+	select {
+	case result := <-results:
+		return result.body, result.err
+	case <-requestContext.Done():
+		return nil, requestContext.Err()
+	}
+}
+
+func (ctx *Context) validateJSONSchema(schema []byte, rawJSON []byte) bool {
+	if ctx.config.JSONSchemaValidator == nil {
+		ctx.InternalServerError(fmt.Errorf("the request model has a schema, but no JSONSchemaValidator is configured"))
+		return false
+	}
+
+	schemaErrors, err := ctx.config.JSONSchemaValidator(schema, rawJSON)
+	if err != nil {
+		ctx.InternalServerError(err)
+		return false
+	}
+
+	if len(schemaErrors) > 0 {
+		problem := ctx.getProblemDetailsForSchemaValidation(schemaErrors)
+		ctx.respondWithProblem(http.StatusUnprocessableEntity, problem)
+		return false
+	}
+
+	return true
+}
+
+// problemDetailsWithInstance adds the RFC 7807 instance member, which is not
+// supported by problem.Details, to a problem.
+type problemDetailsWithInstance struct {
+	*problem.Details
+	Instance string `json:"instance"`
+}
+
+func (ctx *Context) respondWithProblem(code int, problem *problem.Details) {
+	var model interface{} = problem
+	if ctx.config.ProblemDetailsInstance {
+		// The correlation ID is left out of the instance when it is not to be
+		// exposed to clients.
+		instance := fmt.Sprintf("%v#%v", ctx.r.URL.Path, ctx.correlationID)
+		if ctx.config.SuppressCorrelationIDHeader {
+			instance = ctx.r.URL.Path
+		}
+
+		model = &problemDetailsWithInstance{
+			Details:  problem,
+			Instance: instance,
+		}
+	}
+
+	if ctx.config.HTMLErrorTemplate != nil && prefersHTML(ctx.r.Header.Get("Accept")) {
+		if ctx.respondWithHTMLProblem(code, model) {
+			return
+		}
+	}
+
+	ctx.respondWithJSONContentType(code, model, "application/json")
+}
+
+// respondWithHTMLProblem renders the provided problem with
+// Config.HTMLErrorTemplate.  If rendering fails, nothing is written and false
+// is returned, so that the problem can be sent as JSON instead.
+func (ctx *Context) respondWithHTMLProblem(code int, model interface{}) bool {
+	if ctx.ResponseWritten() {
+		return true
+	}
+
+	buf := &bytes.Buffer{}
+	err := ctx.config.HTMLErrorTemplate.Execute(buf, model)
+	if err != nil {
+		return false
+	}
+
+	ctx.w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	ctx.setContentLength(buf.Len())
+	ctx.Respond(code)
+	ctx.w.Write(buf.Bytes())
+
+	return true
+}
+
+func (ctx *Context) respondWithJSONContentType(code int, model interface{}, contentType string) {
+	if ctx.ResponseWritten() {
+		return
+	}
+
+	buf := jsonBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer releaseJSONBuffer(buf)
+
+	err := json.NewEncoder(buf).Encode(model)
+	if err != nil {
+		buf.Reset()
+		buf.Write(ctx.getRawProblemDetailsForSerializationError(err))
+		code = http.StatusInternalServerError
+		contentType = "application/json"
+	}
+
+	// json.Encoder terminates each value with a newline, which json.Marshal
+	// does not.
+	rawJSON := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+
+	ctx.w.Header().Set("Content-Type", contentType)
+	ctx.setContentLength(len(rawJSON))
+	ctx.Respond(code)
+	ctx.w.Write(rawJSON)
+}
+
+func releaseJSONBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledJSONBufferSize {
+		return
+	}
+
+	jsonBufferPool.Put(buf)
+}
+
+// setContentLength sets the Content-Length header of the response, unless a
+// Content-Encoding or Transfer-Encoding header is present, in which case the
+// length of the written body will differ and any Content-Length is removed.
+func (ctx *Context) setContentLength(length int) {
+	header := ctx.w.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Transfer-Encoding") != "" {
+		header.Del("Content-Length")
+		return
+	}
+
+	header.Set("Content-Length", fmt.Sprintf("%v", length))
+}
+
+func (ctx *Context) correlationIDHeader() string {
+	if ctx.config.CorrelationIDHeader == "" {
+		return DefaultCorrelationIDHeader
+	}
+
+	return ctx.config.CorrelationIDHeader
+}
+
+// problemType returns the type of a problem for the provided status code, using
+// the slug from Config.ProblemSlugs if one is set and the provided default
+// slug otherwise.
+func (ctx *Context) problemType(code int, defaultSlug string) string {
+	slug, ok := ctx.config.ProblemSlugs[code]
+	if !ok {
+		slug = defaultSlug
+	}
+
+	return fmt.Sprintf("%v/http/%v", ctx.config.ProblemDetailsTypePrefix, slug)
+}
+
+// problemTitle returns the title of a problem for the provided status code,
+// using the title from Config.ProblemTitles if one is set and the provided
+// default title otherwise.
+func (ctx *Context) problemTitle(code int, defaultTitle string) string {
+	if title, ok := ctx.config.ProblemTitles[code]; ok {
+		return title
+	}
+
+	return defaultTitle
+}
+
+// attachError attaches the provided error to the provided problem if debugging
+// is enabled.  Otherwise, the error is attached as transformed by
+// Config.ErrorSanitizer, if it is set.
+func (ctx *Context) attachError(problem *problem.Details, err error) {
+	if err == nil {
+		return
+	}
+
+	if ctx.config.DebuggingEnabled {
+		problem.AttachError(err)
+		return
+	}
+
+	problem.Error = ctx.sanitizeError(err)
+}
+
+func (ctx *Context) sanitizeError(err error) string {
+	if err == nil || ctx.config.ErrorSanitizer == nil {
+		return ""
+	}
+
+	return ctx.config.ErrorSanitizer(err)
+}
+
+func (ctx *Context) flushResponseBuffer() error {
+	if ctx.brw == nil {
+		return nil
+	}
+
+	if !ctx.brw.HasCommitted() && len(ctx.responseTransformers) > 0 {
+		body := ctx.brw.Body()
+		for _, transform := range ctx.responseTransformers {
+			body = transform(ctx.brw.StatusCode(), body)
+		}
+
+		ctx.brw.SetBody(body)
+	}
+
+	return ctx.brw.Commit()
+}
+
+// whenResponseComplete calls fn once the response has been written to the
+// underlying http.ResponseWriter.  If the response is not being buffered, or
+// the buffer has already been committed, fn is called immediately.  Otherwise,
+// it is called after the buffer is committed at the end of the request.
+func (ctx *Context) whenResponseComplete(fn func()) {
+	if ctx.brw == nil || ctx.brw.HasCommitted() {
+		fn()
+		return
+	}
+
+	ctx.completionHooks = append(ctx.completionHooks, fn)
+}
+
+func (ctx *Context) runCompletionHooks() {
+	hooks := ctx.completionHooks
+	ctx.completionHooks = nil
+
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+func (ctx *Context) discardResponseBuffer() {
+	if ctx.brw == nil || ctx.brw.HasCommitted() {
+		return
+	}
+
+	ctx.w = ctx.brw.w
+	ctx.brw = nil
+}
+
+func (ctx *Context) intQueryParameter(name string, defaultValue int) (int, bool) {
+	raw := strings.TrimSpace(ctx.GetQueryParameter(name))
+	if raw == "" {
+		return defaultValue, true
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		problem := ctx.getProblemDetailsForInvalidQueryParameter(&fieldBindingError{
+			name:     name,
+			value:    raw,
+			typeName: "int",
+			err:      err,
+		})
+
+		ctx.respondWithProblem(http.StatusBadRequest, problem)
+		return 0, false
+	}
+
+	return value, true
+}
+
+func (ctx *Context) pageURL(page int, pageSize int) string {
+	query := ctx.r.URL.Query()
+	query.Set("page", fmt.Sprintf("%v", page))
+	query.Set("pageSize", fmt.Sprintf("%v", pageSize))
+
+	return ctx.AbsoluteURL(fmt.Sprintf("%v?%v", ctx.r.URL.EscapedPath(), query.Encode()))
+}
+
+func (ctx *Context) isFromTrustedProxy() bool {
+	host := remoteAddrHost(ctx.r.RemoteAddr)
+	return isTrustedProxy(net.ParseIP(host), ctx.config.TrustedProxies)
+}
+
+func (ctx *Context) redirectToPath(path string, code int) {
+	u := *ctx.r.URL
+	u.Path = path
+	u.RawPath = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	ctx.w.Header().Set("Location", u.RequestURI())
+	ctx.Respond(code)
+}
+
+func (ctx *Context) getProblemDetailsForUnsupportedMediaType(providedContentType string, allowedContentTypes []string) *problem.Details {
+	return &problem.Details{
+		Type:   ctx.problemType(http.StatusUnsupportedMediaType, "unsupported-media-type"),
+		Title:  ctx.problemTitle(http.StatusUnsupportedMediaType, "Unsupported Media Type"),
+		Detail: fmt.Sprintf("The Content-Type '%v' is not supported by this endpoint.", providedContentType),
+		Specifics: map[string]interface{}{
+			"providedContentType": providedContentType,
+			"allowedContentTypes": allowedContentTypes,
+		},
+	}
+}
+
+func (ctx *Context) getProblemDetailsForRequestEntityTooLarge(contentLength, max int64) *problem.Details {
+	detailFormat := "The provided request entity of length %v (%v bytes) exceeds the maximum of %v (%v bytes) on this endpoint."
+	return &problem.Details{
+		Type:   ctx.problemType(http.StatusRequestEntityTooLarge, "request-entity-too-large"),
+		Title:  ctx.problemTitle(http.StatusRequestEntityTooLarge, "Request Entity Too Large"),
+		Detail: fmt.Sprintf(detailFormat, ByteSizeToFriendlyString(contentLength), contentLength, ByteSizeToFriendlyString(max), max),
+		Specifics: map[string]interface{}{
+			"contentLength":        contentLength,
+			"maximumContentLength": max,
+		},
+	}
+}
+
+func (ctx *Context) getProblemDetailsForRequestEntityTooLargeWithUnknownLength(max int64) *problem.Details {
+	detailFormat := "The provided request entity exceeds the maximum of %v (%v bytes) on this endpoint."
+	return &problem.Details{
+		Type:   ctx.problemType(http.StatusRequestEntityTooLarge, "request-entity-too-large"),
+		Title:  ctx.problemTitle(http.StatusRequestEntityTooLarge, "Request Entity Too Large"),
+		Detail: fmt.Sprintf(detailFormat, ByteSizeToFriendlyString(max), max),
+		Specifics: map[string]interface{}{
+			"maximumContentLength": max,
+		},
+	}
+}
+
+func (ctx *Context) getProblemDetailsForMalformedMultipartBody(err error) *problem.Details {
+	problem := &problem.Details{
+		Type:   fmt.Sprintf("%v/http/malformed-multipart-body", ctx.config.ProblemDetailsTypePrefix),
+		Title:  "Malformed Multipart Body",
+		Detail: "The provided request body is not a valid multipart/form-data body.",
+	}
+
+	ctx.attachError(problem, err)
+
+	return problem
+}
+
+func (ctx *Context) getProblemDetailsForMissingFormFile(name string) *problem.Details {
+	return &problem.Details{
+		Type:   fmt.Sprintf("%v/http/missing-form-file", ctx.config.ProblemDetailsTypePrefix),
+		Title:  "Missing Form File",
+		Detail: fmt.Sprintf("This endpoint requires the file '%v'.", name),
+		Specifics: map[string]interface{}{
+			"name": name,
+		},
+	}
+}
+
+func (ctx *Context) getProblemDetailsForUnreadableBody(err error) *problem.Details {
+	problem := &problem.Details{
+		Type:   fmt.Sprintf("%v/http/unreadable-body", ctx.config.ProblemDetailsTypePrefix),
+		Title:  "Unreadable Request Body",
+		Detail: "The provided request body could not be read.",
+	}
+
+	ctx.attachError(problem, err)
+
+	return problem
+}
+
+func (ctx *Context) getProblemDetailsForRequestTimeout(detail string) *problem.Details {
+	return &problem.Details{
+		Type:   ctx.problemType(http.StatusRequestTimeout, "request-timeout"),
+		Title:  ctx.problemTitle(http.StatusRequestTimeout, "Request Timeout"),
+		Detail: detail,
+	}
+}
+
+func (ctx *Context) getProblemDetailsForRequestHeaderFieldsTooLarge(detail string) *problem.Details {
+	return &problem.Details{
+		Type:   ctx.problemType(http.StatusRequestHeaderFieldsTooLarge, "request-header-fields-too-large"),
+		Title:  ctx.problemTitle(http.StatusRequestHeaderFieldsTooLarge, "Request Header Fields Too Large"),
+		Detail: detail,
+	}
+}
+
+func (ctx *Context) getProblemDetailsForPreconditionFailed(detail string) *problem.Details {
+	return &problem.Details{
+		Type:   ctx.problemType(http.StatusPreconditionFailed, "precondition-failed"),
+		Title:  ctx.problemTitle(http.StatusPreconditionFailed, "Precondition Failed"),
+		Detail: detail,
+	}
+}
+
+func (ctx *Context) getProblemDetailsForPreconditionRequired(detail string) *problem.Details {
+	return &problem.Details{
+		Type:   ctx.problemType(http.StatusPreconditionRequired, "precondition-required"),
+		Title:  ctx.problemTitle(http.StatusPreconditionRequired, "Precondition Required"),
+		Detail: detail,
+	}
+}
+
+func (ctx *Context) getProblemDetailsForInvalidSignature(header string) *problem.Details {
+	return &problem.Details{
+		Type:   fmt.Sprintf("%v/http/invalid-signature", ctx.config.ProblemDetailsTypePrefix),
+		Title:  "Invalid Signature",
+		Detail: fmt.Sprintf("The signature provided in the '%v' header is missing or invalid.", header),
+		Specifics: map[string]interface{}{
+			"header": header,
+		},
+	}
+}
+
+func (ctx *Context) getProblemDetailsForLengthRequired() *problem.Details {
+	return &problem.Details{
+		Type:   ctx.problemType(http.StatusLengthRequired, "length-required"),
+		Title:  ctx.problemTitle(http.StatusLengthRequired, "Length Required"),
+		Detail: "This endpoint requires that the Content-Length header be set to a positive, non-zero value.",
+	}
+}
+
+func (ctx *Context) getProblemDetailsForMethodNotAllowed(method string, allowedMethods []string) *problem.Details {
+	return &problem.Details{
+		Type:   ctx.problemType(http.StatusMethodNotAllowed, "method-not-allowed"),
+		Title:  ctx.problemTitle(http.StatusMethodNotAllowed, "Method Not Allowed"),
+		Detail: fmt.Sprintf(`This endpoint does not allow use of the '%v' method.`, method),
+		Specifics: map[string]interface{}{
+			"methodUsed":     method,
+			"allowedMethods": allowedMethods,
+		},
+	}
+}
+
+func (ctx *Context) getProblemDetailsForMissingQueryParameters(missingQueryParameters []string) *problem.Details {
+	return &problem.Details{
+		Type:   fmt.Sprintf("%v/http/missing-query-parameters", ctx.config.ProblemDetailsTypePrefix),
+		Title:  "Missing Query Parameters",
+		Detail: fmt.Sprintf("This endpoint requires the query parameters '%v'.", strings.Join(missingQueryParameters, "', '")),
+		Specifics: map[string]interface{}{
+			"missingQueryParameters": missingQueryParameters,
+		},
+	}
+}
+
+func (ctx *Context) getProblemDetailsForMissingHeaders(missingHeaders []string) *problem.Details {
+	return &problem.Details{
+		Type:   fmt.Sprintf("%v/http/missing-headers", ctx.config.ProblemDetailsTypePrefix),
+		Title:  "Missing Headers",
+		Detail: fmt.Sprintf("This endpoint requires the headers '%v'.", strings.Join(missingHeaders, "', '")),
+		Specifics: map[string]interface{}{
+			"missingHeaders": missingHeaders,
+		},
+	}
+}
+
+func (ctx *Context) getProblemDetailsForInvalidQueryParameter(bindingErr *fieldBindingError) *problem.Details {
+	return &problem.Details{
+		Type:   fmt.Sprintf("%v/http/invalid-query-parameter", ctx.config.ProblemDetailsTypePrefix),
+		Title:  "Invalid Query Parameter",
+		Detail: fmt.Sprintf("The value '%v' for the query parameter '%v' is not a valid %v.", bindingErr.value, bindingErr.name, bindingErr.typeName),
+		Specifics: map[string]interface{}{
+			"parameter":    bindingErr.name,
+			"value":        bindingErr.value,
+			"expectedType": bindingErr.typeName,
+		},
+	}
+}
+
+func (ctx *Context) getProblemDetailsForInvalidHeader(bindingErr *fieldBindingError) *problem.Details {
+	return &problem.Details{
+		Type:   fmt.Sprintf("%v/http/invalid-header", ctx.config.ProblemDetailsTypePrefix),
+		Title:  "Invalid Header",
+		Detail: fmt.Sprintf("The value '%v' for the header '%v' is not a valid %v.", bindingErr.value, bindingErr.name, bindingErr.typeName),
+		Specifics: map[string]interface{}{
+			"header":       bindingErr.name,
+			"value":        bindingErr.value,
+			"expectedType": bindingErr.typeName,
+		},
+	}
+}
+
+func (ctx *Context) getProblemDetailsForEmptyBody() *problem.Details {
+	return &problem.Details{
+		Type:   fmt.Sprintf("%v/json/empty-body", ctx.config.ProblemDetailsTypePrefix),
+		Title:  "Empty Request Body",
+		Detail: "A JSON request body is required, but the provided request body was empty.",
+	}
+}
+
+func (ctx *Context) getProblemDetailsForMaximumDepthExceeded(maxDepth int) *problem.Details {
+	return &problem.Details{
+		Type:   fmt.Sprintf("%v/json/maximum-depth-exceeded", ctx.config.ProblemDetailsTypePrefix),
+		Title:  "Maximum Depth Exceeded",
+		Detail: fmt.Sprintf("The provided request body is nested more deeply than the maximum of %v levels.", maxDepth),
+		Specifics: map[string]interface{}{
+			"maximumDepth": maxDepth,
+		},
+	}
+}
+
+func (ctx *Context) getProblemDetailsForMergePatchNotObject() *problem.Details {
+	return &problem.Details{
+		Type:   fmt.Sprintf("%v/json/merge-patch-not-object", ctx.config.ProblemDetailsTypePrefix),
+		Title:  "Merge Patch Not An Object",
+		Detail: "The provided merge patch must be a JSON object.",
+	}
+}
+
+func (ctx *Context) getProblemDetailsForDeserialization(err error) *problem.Details {
+	problem := &problem.Details{
+		Type:   fmt.Sprintf("%v/json/deserialization", ctx.config.ProblemDetailsTypePrefix),
+		Title:  "Deserialization Error",
+		Detail: "The provided request body could not be meaningfully deserialized.  It appears to be invalid.",
+	}
+
+	if ctx.config.DebuggingEnabled {
+		err = describeJSONError(err)
+	}
+
+	ctx.attachError(problem, err)
+
+	return problem
+}
+
+func (ctx *Context) getProblemDetailsForSchemaValidation(schemaErrors []string) *problem.Details {
+	return &problem.Details{
+		Type:   fmt.Sprintf("%v/json/schema-validation", ctx.config.ProblemDetailsTypePrefix),
+		Title:  "Schema Validation Failed",
+		Detail: "The provided request body does not conform to the schema for this endpoint.",
+		Specifics: map[string]interface{}{
+			"errors": schemaErrors,
+		},
+	}
+}
+
+func (ctx *Context) getProblemDetailsForUnprocessableEntity(invalidFields []invalidField) *problem.Details {
+	fieldErrors := []map[string]string{}
+	for _, invalidField := range invalidFields {
+		fieldErrors = append(fieldErrors, map[string]string{
+			"field": invalidField.field,
+			"error": invalidField.err.Error(),
+		})
+	}
+
+	return &problem.Details{
+		Type:   ctx.problemType(http.StatusUnprocessableEntity, "unprocessable-entity"),
+		Title:  ctx.problemTitle(http.StatusUnprocessableEntity, "Unprocessable Entity"),
+		Detail: fmt.Sprintf(`The provided request body was understood but contained some invalid values.`),
+		Specifics: map[string]interface{}{
+			"field":  invalidFields[0].field,
+			"error":  invalidFields[0].err.Error(),
+			"errors": fieldErrors,
+		},
+	}
+}
+
+func (ctx *Context) getProblemDetailsForNotFound(subjectType string, subject string) *problem.Details {
+	return &problem.Details{
+		Type:   ctx.problemType(http.StatusNotFound, "not-found"),
+		Title:  ctx.problemTitle(http.StatusNotFound, "Not Found"),
+		Detail: fmt.Sprintf(`The %v '%v' was not found.`, subjectType, subject),
+		Specifics: map[string]interface{}{
+			"subjectType": subjectType,
+			"subject":     subject,
+		},
+	}
+}
+
+func (ctx *Context) getProblemDetailsForGone(subjectType string, subject string) *problem.Details {
+	return &problem.Details{
+		Type:   ctx.problemType(http.StatusGone, "gone"),
+		Title:  ctx.problemTitle(http.StatusGone, "Gone"),
+		Detail: fmt.Sprintf(`The %v '%v' is no longer available.`, subjectType, subject),
+		Specifics: map[string]interface{}{
+			"subjectType": subjectType,
+			"subject":     subject,
+		},
+	}
+}
+
+func (ctx *Context) getProblemDetailsForRetryableProblem(code int, slug string, detail string, retryAfterSeconds int64) *problem.Details {
+	specifics := map[string]interface{}{
+		"retryable": true,
+	}
+
+	if retryAfterSeconds > 0 {
+		specifics["retryAfterSeconds"] = retryAfterSeconds
+	}
+
+	return &problem.Details{
+		Type:      fmt.Sprintf("%v/http/%v", ctx.config.ProblemDetailsTypePrefix, slug),
+		Title:     ctx.problemTitle(code, http.StatusText(code)),
+		Detail:    detail,
+		Specifics: specifics,
+	}
+}
+
+func (ctx *Context) getProblemDetailsForIdempotencyKeyInFlight(idempotencyKey string) *problem.Details {
+	return &problem.Details{
+		Type:   fmt.Sprintf("%v/http/idempotency-key-in-flight", ctx.config.ProblemDetailsTypePrefix),
+		Title:  "Conflict",
+		Detail: fmt.Sprintf("A request with the Idempotency-Key '%v' is already being processed.", idempotencyKey),
+		Specifics: map[string]interface{}{
+			"idempotencyKey": idempotencyKey,
+		},
+	}
+}
+
+func (ctx *Context) getProblemDetailsForHTTPError(httpErr HTTPError) *problem.Details {
+	code := httpErr.status()
+
+	problemType := fmt.Sprintf("%v/http/%v", ctx.config.ProblemDetailsTypePrefix, httpErr.Slug)
+	if httpErr.Slug == "" {
+		problemType = ctx.problemType(code, strings.ToLower(strings.ReplaceAll(http.StatusText(code), " ", "-")))
+	}
+
+	title := httpErr.Title
+	if title == "" {
+		title = ctx.problemTitle(code, http.StatusText(code))
+	}
+
+	problem := &problem.Details{
+		Type:   problemType,
+		Title:  title,
+		Detail: httpErr.Detail,
+	}
+
+	if httpErr.Specifics != nil {
+		problem.Specifics = httpErr.Specifics
+	}
+
+	return problem
+}
+
+func (ctx *Context) getProblemDetailsForInternalServerError(err error) *problem.Details {
+	problem := &problem.Details{
+		Type:   ctx.problemType(http.StatusInternalServerError, "internal-server-error"),
+		Title:  ctx.problemTitle(http.StatusInternalServerError, "Internal Server Error"),
+		Detail: fmt.Sprintf("An internal server error prevented the request from completing."),
+	}
+
+	ctx.attachError(problem, err)
+
+	return problem
+}
+
+func (ctx *Context) getRawProblemDetailsForSerializationError(err error) []byte {
+	formatJSON := `{"type":%s,"title":%s,"detail":"Serialization of the response model failed."%v}`
 
 	errStr := ""
 	if ctx.config.DebuggingEnabled && err != nil {
 		errStr = fmt.Sprintf(`,"error":"%v"`, err.Error())
+	} else if msg := ctx.sanitizeError(err); msg != "" {
+		rawMsg, _ := json.Marshal(msg)
+		errStr = fmt.Sprintf(`,"error":%s`, rawMsg)
 	}
 
-	return []byte(fmt.Sprintf(formatJSON, ctx.config.ProblemDetailsTypePrefix, errStr))
+	rawType, _ := json.Marshal(ctx.problemType(http.StatusInternalServerError, "internal-server-error"))
+	rawTitle, _ := json.Marshal(ctx.problemTitle(http.StatusInternalServerError, "Internal Server Error"))
+
+	return []byte(fmt.Sprintf(formatJSON, rawType, rawTitle, errStr))
 }
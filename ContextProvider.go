@@ -0,0 +1,21 @@
+package web
+
+// ContextProvider provides access to the Context of a request.  NewContext
+// registers a ContextProvider into the container of each Context, so that
+// dependencies resolved for a request can access it.  Dependencies that
+// resolve a ContextProvider should not be registered with di.Singleton, as the
+// first Context resolved would be retained for the lifetime of the
+// application.
+type ContextProvider interface {
+	Context() *Context
+}
+
+type contextProvider struct {
+	ctx *Context
+}
+
+var _ ContextProvider = &contextProvider{}
+
+func (p *contextProvider) Context() *Context {
+	return p.ctx
+}
@@ -2,15 +2,26 @@ package web
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ljpx/di"
+	"github.com/ljpx/id"
+	"github.com/ljpx/logging"
 	"github.com/ljpx/problem"
 	"github.com/ljpx/test"
 )
@@ -41,6 +52,72 @@ func SetupContextTestFixture() *ContextTestFixture {
 	return fixture
 }
 
+func TestContextResolvableFromFactory(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.c.Register(di.InstancePerDependency, func(c di.Container) (testRequestAwareService, error) {
+		var provider ContextProvider
+		err := c.Resolve(&provider)
+		if err != nil {
+			return nil, err
+		}
+
+		return &testRequestAwareStruct{ctx: provider.Context()}, nil
+	})
+
+	fixture.x = NewContext(fixture.w, fixture.r, fixture.c, fixture.x.config)
+
+	// Act.
+	var service testRequestAwareService
+	ok := fixture.x.Resolve(&service)
+
+	// Assert.
+	test.That(t, ok).IsTrue()
+	test.That(t, service.CorrelationID()).IsEqualTo(fixture.x.GetCorrelationID())
+}
+
+func TestContextProviderNotRegisteredInParentContainer(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	var provider ContextProvider
+	err := fixture.c.Resolve(&provider)
+
+	// Assert.
+	test.That(t, err).IsNotNil()
+}
+
+func TestContextMethodAndPath(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPatch, "/users/1234?fields=name", nil)
+	fixture.x.r = fixture.r
+
+	// Act.
+	method := fixture.x.Method()
+	path := fixture.x.Path()
+
+	// Assert.
+	test.That(t, method).IsEqualTo(http.MethodPatch)
+	test.That(t, path).IsEqualTo("/users/1234")
+}
+
+func TestContextGetQueryParameters(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodGet, "/?tag=a&limit=10&tag=b", nil)
+	fixture.x.r = fixture.r
+
+	// Act.
+	tags := fixture.x.GetQueryParameters("tag")
+	missing := fixture.x.GetQueryParameters("missing")
+
+	// Assert.
+	test.That(t, strings.Join(tags, ",")).IsEqualTo("a,b")
+	test.That(t, len(missing)).IsEqualTo(0)
+}
+
 func TestContextRequestAndResponseWriter(t *testing.T) {
 	// Arrange.
 	fixture := SetupContextTestFixture()
@@ -80,321 +157,2364 @@ func TestContextResolveFailure(t *testing.T) {
 	success := fixture.x.Resolve(&val)
 
 	// Assert.
-	test.That(t, success).IsFalse()
-
+	test.That(t, success).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusInternalServerError)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := "{\"type\":\"https://testi.ng/http/internal-server-error\",\"title\":\"Internal Server Error\",\"detail\":\"An internal server error prevented the request from completing.\",\"error\":\"the type `io.Writer` does not have a resolver in this container\"}"
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextResolveNilContainer(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x = NewContext(fixture.w, fixture.r, nil, fixture.x.config)
+
+	// Act.
+	var val testInterface
+	success := fixture.x.Resolve(&val)
+
+	// Assert.
+	test.That(t, success).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusInternalServerError)
+
+	problemDetails := &problem.Details{}
+	err := UnmarshalFromResponse(res, problemDetails)
+	test.That(t, err).IsNil()
+	test.That(t, problemDetails.Type).IsEqualTo("https://testi.ng/http/internal-server-error")
+	test.That(t, problemDetails.Error).IsEqualTo("the type `web.testInterface` does not have a resolver in this container")
+}
+
+func TestContextStartTime(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	mrw := NewMeasuredResponseWriter(fixture.w)
+	mrw.startTime = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Act.
+	fixture.x = NewContext(mrw, fixture.r, fixture.c, fixture.x.config)
+
+	// Assert.
+	test.That(t, fixture.x.StartTime()).IsEqualTo(mrw.startTime)
+}
+
+func TestContextStartTimeWithoutMeasuredResponseWriter(t *testing.T) {
+	// Arrange.
+	before := time.Now()
+
+	// Act.
+	fixture := SetupContextTestFixture()
+
+	// Assert.
+	startTime := fixture.x.StartTime()
+	test.That(t, startTime.Before(before)).IsFalse()
+	test.That(t, float64(startTime.Sub(before))).IsLessThan(float64(time.Millisecond * 5))
+}
+
+func TestContextMiddlewareArtifactsSymmetric(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	fixture.x.SetMiddlewareArtifact("number", 5)
+	number := fixture.x.GetMiddlewareArtifact("number").(int)
+
+	// Assert.
+	test.That(t, number).IsEqualTo(5)
+}
+
+func TestContextSendsCorrelationID(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	fixture.x.Respond(http.StatusOK)
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+	test.That(t, res.Header.Get("Correlation-ID")).IsEqualTo(fixture.x.correlationID.String())
+}
+
+func TestContextSuppressesCorrelationIDHeader(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.SuppressCorrelationIDHeader = true
+
+	// Act.
+	fixture.x.Respond(http.StatusOK)
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+	test.That(t, res.Header.Get("Correlation-ID")).IsEqualTo("")
+	test.That(t, fixture.x.GetCorrelationID().String()).IsNotEqualTo("")
+
+	req := httptest.NewRequest(http.MethodGet, "https://downstream.example.com/", nil)
+	fixture.x.PropagateHeaders(req)
+	test.That(t, req.Header.Get("Correlation-ID")).IsEqualTo(fixture.x.GetCorrelationID().String())
+}
+
+func TestContextSetHeaderBeforeRespond(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	mrw := NewMeasuredResponseWriter(fixture.w)
+	fixture.x = NewContext(mrw, fixture.r, fixture.c, fixture.x.config)
+
+	// Act.
+	setBefore := fixture.x.SetHeader("X-Before", "before")
+	fixture.x.Respond(http.StatusCreated)
+	setAfter := fixture.x.SetHeader("X-After", "after")
+	fixture.x.Respond(http.StatusOK)
+
+	// Assert.
+	test.That(t, setBefore).IsTrue()
+	test.That(t, setAfter).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusCreated)
+	test.That(t, res.Header.Get("X-Before")).IsEqualTo("before")
+	test.That(t, res.Header.Get("X-After")).IsEqualTo("")
+	test.That(t, fixture.x.StatusCode()).IsEqualTo(http.StatusCreated)
+}
+
+func TestContextResponseWritten(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	mrw := NewMeasuredResponseWriter(fixture.w)
+	fixture.x = NewContext(mrw, fixture.r, fixture.c, fixture.x.config)
+	writtenBefore := fixture.x.ResponseWritten()
+
+	// Act.
+	fixture.x.Respond(http.StatusOK)
+
+	// Assert.
+	test.That(t, writtenBefore).IsFalse()
+	test.That(t, fixture.x.ResponseWritten()).IsTrue()
+}
+
+func TestContextResponseWrittenWithoutMeasuredResponseWriter(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	writtenBefore := fixture.x.ResponseWritten()
+
+	// Act.
+	fixture.x.Respond(http.StatusOK)
+
+	// Assert.
+	test.That(t, writtenBefore).IsFalse()
+	test.That(t, fixture.x.ResponseWritten()).IsTrue()
+}
+
+func TestContextResponseWrittenWhenBuffered(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.BufferResponse()
+
+	// Act.
+	fixture.x.ResponseWriter().Write([]byte("Hello, World!"))
+
+	// Assert.
+	test.That(t, fixture.x.ResponseWritten()).IsTrue()
+	test.That(t, fixture.w.Body.Len()).IsEqualTo(0)
+}
+
+func TestContextStatusCode(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	mrw := NewMeasuredResponseWriter(fixture.w)
+	fixture.x = NewContext(mrw, fixture.r, fixture.c, fixture.x.config)
+	statusCodeBefore := fixture.x.StatusCode()
+
+	// Act.
+	fixture.x.RespondWithJSON(http.StatusCreated, &testResponseModel{Message: "Hello, World!"})
+
+	// Assert.
+	test.That(t, statusCodeBefore).IsEqualTo(http.StatusOK)
+	test.That(t, fixture.x.StatusCode()).IsEqualTo(http.StatusCreated)
+}
+
+func TestContextStatusCodeWithoutMeasuredResponseWriter(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	fixture.x.RespondWithJSON(http.StatusCreated, &testResponseModel{Message: "Hello, World!"})
+
+	// Assert.
+	test.That(t, fixture.x.StatusCode()).IsEqualTo(http.StatusCreated)
+}
+
+func TestContextSendsCorrelationIDWithConfiguredHeader(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.CorrelationIDHeader = "X-Request-ID"
+
+	// Act.
+	fixture.x.Respond(http.StatusOK)
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.Header.Get("X-Request-ID")).IsEqualTo(fixture.x.correlationID.String())
+	test.That(t, res.Header.Get("Correlation-ID")).IsEqualTo("")
+}
+
+func TestContextPropagateHeaders(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.PropagatedHeaders = []string{"traceparent", "X-Missing"}
+	fixture.r.Header.Set("Traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	fixture.r.Header.Set("X-Not-Propagated", "value")
+
+	req, err := http.NewRequest(http.MethodGet, "http://downstream.example.com/", nil)
+	test.That(t, err).IsNil()
+
+	// Act.
+	fixture.x.PropagateHeaders(req)
+
+	// Assert.
+	test.That(t, req.Header.Get("Correlation-ID")).IsEqualTo(fixture.x.correlationID.String())
+	test.That(t, req.Header.Get("Traceparent")).IsEqualTo("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	test.That(t, len(req.Header)).IsEqualTo(2)
+}
+
+func TestContextRespondWithJSONUnmarshallable(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	fixture.x.RespondWithJSON(http.StatusOK, &testUnmarshallableStruct{})
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusInternalServerError)
+
+	problemDetails := &problem.Details{}
+	err := UnmarshalFromResponse(res, problemDetails)
+	test.That(t, err).IsNil()
+
+	test.That(t, problemDetails.Type).IsEqualTo("https://testi.ng/http/internal-server-error")
+	test.That(t, problemDetails.Title).IsEqualTo("Internal Server Error")
+	test.That(t, problemDetails.Error).IsNotEqualTo("")
+}
+
+func TestContextRespondWithJSONSuccess(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	fixture.x.RespondWithJSON(http.StatusOK, &testResponseModel{Message: "Hello, World!"})
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+
+	responseModel := &testResponseModel{}
+	err := UnmarshalFromResponse(res, responseModel)
+	test.That(t, err).IsNil()
+	test.That(t, responseModel.Message).IsEqualTo("Hello, World!")
+	test.That(t, res.Header.Get("Content-Type")).IsEqualTo("application/json")
+	test.That(t, res.Header.Get("Content-Length")).IsEqualTo("27")
+}
+
+func TestContextRespondWithJSONTwice(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.RespondWithJSON(http.StatusOK, &testResponseModel{Message: "Hello, World!"})
+
+	// Act.
+	fixture.x.RespondWithJSON(http.StatusCreated, &testResponseModel{Message: "Goodbye!"})
+	fixture.x.RespondWithRawJSON(http.StatusCreated, []byte(`{"message":"Goodbye!"}`))
+	fixture.x.RespondWithCSV(http.StatusCreated, []string{"message"}, [][]string{{"Goodbye!"}}, "goodbye.csv")
+	fixture.x.Redirect("/goodbye", http.StatusSeeOther)
+	fixture.x.NotFound("User", "1234")
+	err := fixture.x.Download("goodbye.txt", "", strings.NewReader("Goodbye!"))
+
+	// Assert.
+	test.That(t, err).IsNotNil()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+	test.That(t, res.Header.Get("Content-Type")).IsEqualTo("application/json")
+	test.That(t, res.Header.Get("Content-Length")).IsEqualTo("27")
+	test.That(t, res.Header.Get("Location")).IsEqualTo("")
+	test.That(t, res.Header.Get("Content-Disposition")).IsEqualTo("")
+	test.That(t, fixture.w.Body.String()).IsEqualTo(`{"message":"Hello, World!"}`)
+}
+
+func TestContextReleaseJSONBufferDropsLargeBuffers(t *testing.T) {
+	// Arrange.
+	buf := &bytes.Buffer{}
+	buf.Grow(maxPooledJSONBufferSize + 1)
+
+	// Act.
+	releaseJSONBuffer(buf)
+	pooled := jsonBufferPool.Get().(*bytes.Buffer)
+
+	// Assert.
+	test.That(t, pooled == buf).IsFalse()
+}
+
+func TestContextRespondWithJSONMatchesMarshal(t *testing.T) {
+	// Arrange.
+	model := &testResponseModel{Message: "<Hello> & \"World\"\n"}
+	expected, _ := json.Marshal(model)
+
+	for i := 0; i < 3; i++ {
+		fixture := SetupContextTestFixture()
+
+		// Act.
+		fixture.x.RespondWithJSON(http.StatusOK, model)
+
+		// Assert.
+		test.That(t, fixture.w.Body.String()).IsEqualTo(string(expected))
+		test.That(t, fixture.w.Header().Get("Content-Length")).IsEqualTo(fmt.Sprintf("%v", len(expected)))
+	}
+}
+
+func BenchmarkContextRespondWithJSON(b *testing.B) {
+	model := &testResponseModel{Message: "Hello, World!"}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	config := &Config{ProblemDetailsTypePrefix: "https://testi.ng"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		w.Body.Reset()
+		ctx := NewContext(w, r, nil, config)
+		ctx.RespondWithJSON(http.StatusOK, model)
+	}
+}
+
+// BenchmarkContextRespondWithJSONMarshal measures the json.Marshal path that
+// RespondWithJSON used before it encoded into pooled buffers, for comparison
+// with BenchmarkContextRespondWithJSON.
+func BenchmarkContextRespondWithJSONMarshal(b *testing.B) {
+	model := &testResponseModel{Message: "Hello, World!"}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	config := &Config{ProblemDetailsTypePrefix: "https://testi.ng"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		w.Body.Reset()
+		ctx := NewContext(w, r, nil, config)
+
+		rawJSON, err := json.Marshal(model)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		ctx.w.Header().Set("Content-Type", "application/json")
+		ctx.setContentLength(len(rawJSON))
+		ctx.Respond(http.StatusOK)
+		ctx.w.Write(rawJSON)
+	}
+}
+
+func TestContextRespondWithJSONLargerThanPooledBuffer(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	model := &testResponseModel{Message: strings.Repeat("a", maxPooledJSONBufferSize*2)}
+
+	// Act.
+	fixture.x.RespondWithJSON(http.StatusOK, model)
+
+	// Assert.
+	res := fixture.w.Result()
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+	test.That(t, len(rawJSON)).IsEqualTo(maxPooledJSONBufferSize*2 + len(`{"message":""}`))
+	test.That(t, res.Header.Get("Content-Length")).IsEqualTo(fmt.Sprintf("%v", len(rawJSON)))
+
+	expectedJSON, err := json.Marshal(model)
+	test.That(t, err).IsNil()
+	test.That(t, bytes.Equal(rawJSON, expectedJSON)).IsTrue()
+}
+
+func TestContextAcceptedWithModel(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	fixture.x.Accepted("/jobs/123", &testResponseModel{Message: "queued"})
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusAccepted)
+	test.That(t, res.Header.Get("Location")).IsEqualTo("/jobs/123")
+
+	resModel := &testResponseModel{}
+	err := UnmarshalFromResponse(res, resModel)
+	test.That(t, err).IsNil()
+	test.That(t, resModel.Message).IsEqualTo("queued")
+}
+
+func TestContextAcceptedWithoutModel(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	fixture.x.Accepted("/jobs/123", nil)
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusAccepted)
+	test.That(t, res.Header.Get("Location")).IsEqualTo("/jobs/123")
+	test.That(t, res.Header.Get("Content-Type")).IsEqualTo("")
+	test.That(t, fixture.w.Body.Len()).IsEqualTo(0)
+}
+
+func TestContextRespondWithJSONOmitsContentLengthWhenEncoded(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.Header().Set("Content-Encoding", "gzip")
+	fixture.x.Header().Set("Content-Length", "1")
+
+	// Act.
+	fixture.x.RespondWithJSON(http.StatusOK, &testResponseModel{Message: "Hello, World!"})
+
+	// Assert.
+	_, ok := fixture.w.Header()["Content-Length"]
+	test.That(t, ok).IsFalse()
+	test.That(t, fixture.w.Header().Get("Content-Encoding")).IsEqualTo("gzip")
+}
+
+func TestContextDownload(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	err := fixture.x.Download("résumé.txt", "", strings.NewReader("Hello, World!"))
+
+	// Assert.
+	test.That(t, err).IsNil()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+	test.That(t, res.Header.Get("Content-Type")).IsEqualTo("text/plain; charset=utf-8")
+	test.That(t, res.Header.Get("Content-Disposition")).IsEqualTo(`attachment; filename="r_sum_.txt"; filename*=UTF-8''r%C3%A9sum%C3%A9.txt`)
+	test.That(t, fixture.w.Body.String()).IsEqualTo("Hello, World!")
+}
+
+func TestContextRespondWithPageMiddlePage(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodGet, "http://example.com/users?sort=name&page=2", nil)
+	fixture.x.r = fixture.r
+
+	// Act.
+	fixture.x.RespondWithPage(http.StatusOK, []string{"c", "d"}, 2, 2, 5)
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+	test.That(t, res.Header.Get("Link")).IsEqualTo(`<http://example.com/users?page=1&pageSize=2&sort=name>; rel="prev", <http://example.com/users?page=3&pageSize=2&sort=name>; rel="next"`)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+	test.That(t, string(rawJSON)).IsEqualTo(`{"data":["c","d"],"page":2,"pageSize":2,"total":5}`)
+}
+
+func TestContextRespondWithPageOnlyPage(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	fixture.x.RespondWithPage(http.StatusOK, []string{"a"}, 1, 10, 1)
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.Header.Get("Link")).IsEqualTo("")
+}
+
+func TestContextRespondWithJSONDefaultContentType(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.DefaultJSONContentType = "application/vnd.acme+json"
+
+	// Act.
+	fixture.x.RespondWithJSON(http.StatusOK, &testResponseModel{Message: "Hello, World!"})
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+	test.That(t, res.Header.Get("Content-Type")).IsEqualTo("application/vnd.acme+json")
+}
+
+func TestContextProblemIgnoresDefaultContentType(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.DefaultJSONContentType = "application/vnd.acme+json"
+
+	// Act.
+	fixture.x.NotFound("User", "1234")
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusNotFound)
+	test.That(t, res.Header.Get("Content-Type")).IsEqualTo("application/json")
+}
+
+func TestContextAssertContentTypeSuccess(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r.Header.Set("Content-Type", "image/png")
+
+	// Act.
+	passed := fixture.x.AssertContentType("image/PNG")
+
+	// Assert.
+	test.That(t, passed).IsTrue()
+}
+
+func TestContextAssertContentTypeFailure(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r.Header.Set("Content-Type", "image/jpeg")
+
+	// Act.
+	passed := fixture.x.AssertContentType("image/PNG", "image/gif")
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusUnsupportedMediaType)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/unsupported-media-type","title":"Unsupported Media Type","detail":"The Content-Type 'image/jpeg' is not supported by this endpoint.","specifics":{"allowedContentTypes":["image/PNG","image/gif"],"providedContentType":"image/jpeg"}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextAssertContentTypeIgnoresParameters(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	// Act.
+	passed := fixture.x.AssertContentType("application/json")
+
+	// Assert.
+	test.That(t, passed).IsTrue()
+}
+
+func TestContextAssertContentTypeRealWorldSpellings(t *testing.T) {
+	testCases := []struct {
+		given    string
+		allowed  string
+		expected bool
+	}{
+		{given: "Application/JSON; Charset=UTF-8", allowed: "application/json", expected: true},
+		{given: "  application/json  ", allowed: "application/json", expected: true},
+		{given: "application/json;charset=utf-8", allowed: "application/json", expected: true},
+		{given: "APPLICATION/JSON ;  charset = \"utf-8\"", allowed: "application/json", expected: true},
+		{given: "application/json", allowed: "Application/JSON; charset=utf-8", expected: true},
+		{given: "application/vnd.acme.v2+json; version=2", allowed: "application/vnd.acme.v2+json", expected: true},
+		{given: "application/jsonp", allowed: "application/json", expected: false},
+		{given: "", allowed: "application/json", expected: false},
+	}
+
+	for _, testCase := range testCases {
+		// Arrange.
+		fixture := SetupContextTestFixture()
+		fixture.r.Header.Set("Content-Type", testCase.given)
+
+		// Act.
+		passed := fixture.x.AssertContentType(testCase.allowed)
+
+		// Assert.
+		test.That(t, passed).IsEqualTo(testCase.expected)
+	}
+}
+
+func TestContextUnsupportedMediaTypeMatchesAssertContentType(t *testing.T) {
+	// Arrange.
+	asserted := SetupContextTestFixture()
+	asserted.r.Header.Set("Content-Type", "image/jpeg")
+	asserted.x.AssertContentType("image/PNG", "image/gif")
+
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	fixture.x.UnsupportedMediaType("image/jpeg", "image/PNG", "image/gif")
+
+	// Assert.
+	test.That(t, fixture.w.Result().StatusCode).IsEqualTo(asserted.w.Result().StatusCode)
+	test.That(t, fixture.w.Body.String()).IsEqualTo(asserted.w.Body.String())
+}
+
+func TestContextAssertContentLengthSuccess(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodGet, "/", bytes.NewBufferString("Hello, World!"))
+	fixture.r.Header.Set("Content-Length", "13")
+	fixture.x.r = fixture.r
+
+	// Act.
+	passed := fixture.x.AssertContentLength(13)
+
+	// Assert.
+	test.That(t, passed).IsTrue()
+}
+
+func TestContextAssertContentLengthFailureTooLarge(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodGet, "/", bytes.NewBufferString("Hello, World!"))
+	fixture.r.Header.Set("Content-Length", "13")
+	fixture.x.r = fixture.r
+
+	// Act.
+	passed := fixture.x.AssertContentLength(12)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusRequestEntityTooLarge)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/request-entity-too-large","title":"Request Entity Too Large","detail":"The provided request entity of length 13.00 B (13 bytes) exceeds the maximum of 12.00 B (12 bytes) on this endpoint.","specifics":{"contentLength":13,"maximumContentLength":12}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextAssertContentLengthFailureNotProvided(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	passed := fixture.x.AssertContentLength(12)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusLengthRequired)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/length-required","title":"Length Required","detail":"This endpoint requires that the Content-Length header be set to a positive, non-zero value."}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextAssertMethodSuccess(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	passed := fixture.x.AssertMethod(http.MethodPost, http.MethodGet)
+
+	// Assert.
+	test.That(t, passed).IsTrue()
+}
+
+func TestContextAssertMethodFailure(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	passed := fixture.x.AssertMethod(http.MethodPost, http.MethodPut)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusMethodNotAllowed)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/method-not-allowed","title":"Method Not Allowed","detail":"This endpoint does not allow use of the 'GET' method.","specifics":{"allowedMethods":["POST","PUT"],"methodUsed":"GET"}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextAssertQueryParametersSuccess(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodGet, "/?from=2020-01-01&to=2020-02-01", nil)
+	fixture.x.r = fixture.r
+
+	// Act.
+	passed := fixture.x.AssertQueryParameters("from", "to")
+
+	// Assert.
+	test.That(t, passed).IsTrue()
+}
+
+func TestContextAssertQueryParametersFailure(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodGet, "/?from=2020-01-01&to=&limit=5", nil)
+	fixture.x.r = fixture.r
+
+	// Act.
+	passed := fixture.x.AssertQueryParameters("from", "to", "sort")
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusBadRequest)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/missing-query-parameters","title":"Missing Query Parameters","detail":"This endpoint requires the query parameters 'to', 'sort'.","specifics":{"missingQueryParameters":["to","sort"]}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextRawBodySuccess(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":"Hello, World!"}`))
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	rawBody, passed := fixture.x.RawBody()
+
+	// Assert.
+	test.That(t, passed).IsTrue()
+	test.That(t, string(rawBody)).IsEqualTo(`{"message":"Hello, World!"}`)
+
+	reqModel := &testRequestModel{}
+	test.That(t, fixture.x.FromJSON(reqModel)).IsTrue()
+	test.That(t, reqModel.Message).IsEqualTo("Hello, World!")
+}
+
+func TestContextRawBodyTooLarge(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.JSONContentLengthLimit = 12
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("Hello, World!"))
+	fixture.r.ContentLength = -1
+	fixture.x.r = fixture.r
+
+	// Act.
+	rawBody, passed := fixture.x.RawBody()
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+	test.That(t, len(rawBody)).IsEqualTo(0)
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusRequestEntityTooLarge)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/request-entity-too-large","title":"Request Entity Too Large","detail":"The provided request entity exceeds the maximum of 12.00 B (12 bytes) on this endpoint.","specifics":{"maximumContentLength":12}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextRawBodyThenFromJSONThenRequestBody(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":"Hello, World!"}`))
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	_, passed := fixture.x.RawBody()
+	reqModel := &testRequestModel{}
+	decoded := fixture.x.FromJSON(reqModel)
+	rawBody, passedAgain := fixture.x.RawBody()
+
+	// Assert.
+	test.That(t, passed).IsTrue()
+	test.That(t, decoded).IsTrue()
+	test.That(t, passedAgain).IsTrue()
+	test.That(t, reqModel.Message).IsEqualTo("Hello, World!")
+	test.That(t, string(rawBody)).IsEqualTo(`{"message":"Hello, World!"}`)
+
+	requestBody, err := ioutil.ReadAll(fixture.r.Body)
+	test.That(t, err).IsNil()
+	test.That(t, string(requestBody)).IsEqualTo(`{"message":"Hello, World!"}`)
+}
+
+func TestContextRawBodyFailureIsNotRetried(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.JSONContentLengthLimit = 12
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":"Hello, World!"}`))
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.r.ContentLength = 10
+	fixture.x.r = fixture.r
+	fixture.x.RawBody()
+	bodyLength := fixture.w.Body.Len()
+
+	// Act.
+	reqModel := &testRequestModel{}
+	decoded := fixture.x.FromJSON(reqModel)
+
+	// Assert.
+	test.That(t, decoded).IsFalse()
+	test.That(t, fixture.w.Result().StatusCode).IsEqualTo(http.StatusRequestEntityTooLarge)
+	test.That(t, fixture.w.Body.Len()).IsEqualTo(bodyLength)
+}
+
+func TestContextFromJSONSlowBodyTimesOut(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	requestContext, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", &testSlowReader{delay: time.Second})
+	fixture.r = fixture.r.WithContext(requestContext)
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.r.ContentLength = 10
+	fixture.x.r = fixture.r
+
+	// Act.
+	reqModel := &testRequestModel{}
+	passed := fixture.x.FromJSON(reqModel)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusRequestTimeout)
+
+	problem := &problem.Details{}
+	err := UnmarshalFromResponse(res, problem)
+	test.That(t, err).IsNil()
+	test.That(t, problem.Type).IsEqualTo("https://testi.ng/http/request-timeout")
+}
+
+func TestContextFormFileSuccess(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("upload", "hello.txt")
+	part.Write([]byte("Hello, World!"))
+	writer.Close()
+
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", body)
+	fixture.r.Header.Set("Content-Type", writer.FormDataContentType())
+	fixture.x.r = fixture.r
+
+	// Act.
+	file, fileHeader, passed := fixture.x.FormFile("upload")
+
+	// Assert.
+	test.That(t, passed).IsTrue()
+	defer file.Close()
+
+	content, err := ioutil.ReadAll(file)
+	test.That(t, err).IsNil()
+	test.That(t, string(content)).IsEqualTo("Hello, World!")
+	test.That(t, fileHeader.Filename).IsEqualTo("hello.txt")
+}
+
+func TestContextFormFileMalformedBody(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("--other\r\nnot a multipart body"))
+	fixture.r.Header.Set("Content-Type", "multipart/form-data; boundary=expected")
+	fixture.x.r = fixture.r
+
+	// Act.
+	_, _, passed := fixture.x.FormFile("upload")
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusBadRequest)
+
+	problem := &problem.Details{}
+	err := UnmarshalFromResponse(res, problem)
+	test.That(t, err).IsNil()
+	test.That(t, problem.Type).IsEqualTo("https://testi.ng/http/malformed-multipart-body")
+}
+
+func TestContextFormFileMissing(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("name", "value")
+	writer.Close()
+
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", body)
+	fixture.r.Header.Set("Content-Type", writer.FormDataContentType())
+	fixture.x.r = fixture.r
+
+	// Act.
+	_, _, passed := fixture.x.FormFile("upload")
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusBadRequest)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/missing-form-file","title":"Missing Form File","detail":"This endpoint requires the file 'upload'.","specifics":{"name":"upload"}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextContentLengthLimitsByContentType(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.JSONContentLengthLimit = 8
+	fixture.x.config.ContentLengthLimits = map[string]int64{
+		"text/plain": 64,
+	}
+
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("Hello, World!"))
+	fixture.r.Header.Set("Content-Type", "Text/Plain; charset=utf-8")
+	fixture.x.r = fixture.r
+
+	jsonFixture := SetupContextTestFixture()
+	jsonFixture.x.config.JSONContentLengthLimit = 8
+	jsonFixture.x.config.ContentLengthLimits = fixture.x.config.ContentLengthLimits
+
+	jsonFixture.r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"message":"Hello, World!"}`))
+	jsonFixture.r.Header.Set("Content-Type", "application/json")
+	jsonFixture.x.r = jsonFixture.r
+
+	// Act.
+	rawBody, passed := fixture.x.RawBody()
+	jsonPassed := jsonFixture.x.FromJSON(&testRequestModel{})
+
+	// Assert.
+	test.That(t, passed).IsTrue()
+	test.That(t, string(rawBody)).IsEqualTo("Hello, World!")
+
+	test.That(t, jsonPassed).IsFalse()
+	test.That(t, jsonFixture.w.Result().StatusCode).IsEqualTo(http.StatusRequestEntityTooLarge)
+}
+
+func TestContextVerifyHMACSuccess(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":"Hello, World!"}`))
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(`{"message":"Hello, World!"}`))
+	fixture.r.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	// Act.
+	passed := fixture.x.VerifyHMAC("X-Signature", []byte("secret"))
+
+	// Assert.
+	test.That(t, passed).IsTrue()
+
+	reqModel := &testRequestModel{}
+	test.That(t, fixture.x.FromJSON(reqModel)).IsTrue()
+	test.That(t, reqModel.Message).IsEqualTo("Hello, World!")
+}
+
+func TestContextVerifyHMACFailure(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":"Hello, World!"}`))
+	fixture.x.r = fixture.r
+
+	mac := hmac.New(sha256.New, []byte("wrong-secret"))
+	mac.Write([]byte(`{"message":"Hello, World!"}`))
+	fixture.r.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+
+	// Act.
+	passed := fixture.x.VerifyHMAC("X-Signature", []byte("secret"))
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusUnauthorized)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/invalid-signature","title":"Invalid Signature","detail":"The signature provided in the 'X-Signature' header is missing or invalid.","specifics":{"header":"X-Signature"}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextFromJSONContentTypeIncorrect(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":"Hello, World!"}`))
+	fixture.r.Header.Set("Content-Type", "application/not-json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	reqModel := &testRequestModel{}
+	passed := fixture.x.FromJSON(reqModel)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusUnsupportedMediaType)
+}
+
+func TestContextFromJSONSyntaxErrorIncludesOffset(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":}`))
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	reqModel := &testRequestModel{}
+	passed := fixture.x.FromJSON(reqModel)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusBadRequest)
+
+	problem := &problem.Details{}
+	err := UnmarshalFromResponse(res, problem)
+	test.That(t, err).IsNil()
+	test.That(t, problem.Error).IsEqualTo("invalid character '}' looking for beginning of value at byte offset 12")
+}
+
+func TestContextFromJSONTypeErrorIncludesFieldAndType(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":42}`))
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	reqModel := &testRequestModel{}
+	passed := fixture.x.FromJSON(reqModel)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusBadRequest)
+
+	problem := &problem.Details{}
+	err := UnmarshalFromResponse(res, problem)
+	test.That(t, err).IsNil()
+	test.That(t, problem.Error).IsEqualTo("the field 'message' expected string but found number at byte offset 13")
+}
+
+func TestContextFromJSONDecodeErrorHiddenWithoutDebugging(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.DebuggingEnabled = false
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":42}`))
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	passed := fixture.x.FromJSON(&testRequestModel{})
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	problem := &problem.Details{}
+	err := UnmarshalFromResponse(fixture.w.Result(), problem)
+	test.That(t, err).IsNil()
+	test.That(t, problem.Error).IsEqualTo("")
+}
+
+func TestContextFromJSONWithUnsetContentLengthLimit(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.JSONContentLengthLimit = 0
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":"Hello, World!"}`))
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	reqModel := &testRequestModel{}
+	passed := fixture.x.FromJSON(reqModel)
+
+	// Assert.
+	test.That(t, passed).IsTrue()
+	test.That(t, reqModel.Message).IsEqualTo("Hello, World!")
+}
+
+func TestContextFromJSONVendorContentType(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":"Hello, World!"}`))
+	fixture.r.Header.Set("Content-Type", "application/vnd.acme+json; charset=utf-8")
+	fixture.x.r = fixture.r
+
+	// Act.
+	reqModel := &testRequestModel{}
+	passed := fixture.x.FromJSON(reqModel)
+
+	// Assert.
+	test.That(t, passed).IsTrue()
+	test.That(t, reqModel.Message).IsEqualTo("Hello, World!")
+}
+
+func TestContextFromJSONNestedPurifyFailure(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"reference":"abc","address":{"postcode":"invalid"}}`))
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	reqModel := &testPurifyOrder{}
+	passed := fixture.x.FromJSON(reqModel)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusUnprocessableEntity)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/unprocessable-entity","title":"Unprocessable Entity","detail":"The provided request body was understood but contained some invalid values.","specifics":{"error":"cannot be the string 'invalid'","errors":[{"error":"cannot be the string 'invalid'","field":"address.postcode"}],"field":"address.postcode"}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextFromJSONSliceElementPurifyFailure(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"reference":"abc","items":[{"sku":"a"},{"sku":"b"},{"sku":""}]}`))
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	reqModel := &testPurifyOrder{}
+	passed := fixture.x.FromJSON(reqModel)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusUnprocessableEntity)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/unprocessable-entity","title":"Unprocessable Entity","detail":"The provided request body was understood but contained some invalid values.","specifics":{"error":"is required","errors":[{"error":"is required","field":"items[2].sku"}],"field":"items[2].sku"}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextFromJSONContentLengthMissing(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":"Hello, World!"}`))
+	fixture.r.ContentLength = -1
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	reqModel := &testRequestModel{}
+	passed := fixture.x.FromJSON(reqModel)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusLengthRequired)
+}
+
+func TestContextFromJSONInvalidJSON(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":"Hello, World!"`))
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	reqModel := &testRequestModel{}
+	passed := fixture.x.FromJSON(reqModel)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusBadRequest)
+}
+
+func TestContextFromJSONEmptyBody(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", nil)
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	reqModel := &testRequestModel{}
+	passed := fixture.x.FromJSON(reqModel)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusBadRequest)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/json/empty-body","title":"Empty Request Body","detail":"A JSON request body is required, but the provided request body was empty."}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextFromJSONMaximumDepthExceeded(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.MaxJSONDepth = 4
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":"Hello","a":[[[{}]]]}`))
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	reqModel := &testRequestModel{}
+	passed := fixture.x.FromJSON(reqModel)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusBadRequest)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/json/maximum-depth-exceeded","title":"Maximum Depth Exceeded","detail":"The provided request body is nested more deeply than the maximum of 4 levels.","specifics":{"maximumDepth":4}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextFromJSONDefaultMaximumDepth(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	body := `{"message":"Hello","a":` + strings.Repeat("[", DefaultMaxJSONDepth) + strings.Repeat("]", DefaultMaxJSONDepth) + `}`
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	reqModel := &testRequestModel{}
+	passed := fixture.x.FromJSON(reqModel)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+	test.That(t, fixture.w.Result().StatusCode).IsEqualTo(http.StatusBadRequest)
+}
+
+func TestContextFromJSONPurifyFailure(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":"invalid"}`))
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	reqModel := &testRequestModel{}
+	passed := fixture.x.FromJSON(reqModel)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusUnprocessableEntity)
+}
+
+func TestContextFromJSONSchemaValidationFailure(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.JSONSchemaValidator = testJSONSchemaValidator
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"text":"Hello, World!"}`))
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	reqModel := &testSchemaRequestModel{}
+	passed := fixture.x.FromJSON(reqModel)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusUnprocessableEntity)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/json/schema-validation","title":"Schema Validation Failed","detail":"The provided request body does not conform to the schema for this endpoint.","specifics":{"errors":["missing required property 'message'"]}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextFromJSONSchemaValidationSuccess(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.JSONSchemaValidator = testJSONSchemaValidator
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":"Hello, World!"}`))
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	reqModel := &testSchemaRequestModel{}
+	passed := fixture.x.FromJSON(reqModel)
+
+	// Assert.
+	test.That(t, passed).IsTrue()
+	test.That(t, reqModel.Message).IsEqualTo("Hello, World!")
+}
+
+func TestContextFromJSONSchemaWithoutValidator(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":"Hello, World!"}`))
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	reqModel := &testSchemaRequestModel{}
+	passed := fixture.x.FromJSON(reqModel)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+	test.That(t, fixture.w.Result().StatusCode).IsEqualTo(http.StatusInternalServerError)
+}
+
+func TestContextFromJSONSuccess(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":"Hello, World!"}`))
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	reqModel := &testRequestModel{}
+	passed := fixture.x.FromJSON(reqModel)
+
+	// Assert.
+	test.That(t, passed).IsTrue()
+	test.That(t, reqModel.Message).IsEqualTo("Hello, World!")
+}
+
+func TestContextFromJSONMergePatchSuccess(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPatch, "/", bytes.NewBufferString(`{"message":"Goodbye!","tags":null}`))
+	fixture.r.Header.Set("Content-Type", "application/merge-patch+json")
+	fixture.x.r = fixture.r
+
+	current := &testPatchModel{Message: "Hello, World!", Tags: []string{"a"}, Count: 5}
+
+	// Act.
+	patch, passed := fixture.x.FromJSONMergePatch(current)
+
+	// Assert.
+	test.That(t, passed).IsTrue()
+	test.That(t, len(patch)).IsEqualTo(2)
+	test.That(t, patch["message"]).IsEqualTo("Goodbye!")
+	test.That(t, current.Message).IsEqualTo("Goodbye!")
+	test.That(t, len(current.Tags)).IsEqualTo(0)
+	test.That(t, current.Count).IsEqualTo(5)
+}
+
+func TestContextFromJSONMergePatchPurifyFailure(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPatch, "/", bytes.NewBufferString(`{"message":"invalid"}`))
+	fixture.r.Header.Set("Content-Type", "application/merge-patch+json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	_, passed := fixture.x.FromJSONMergePatch(&testPatchModel{})
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+	test.That(t, fixture.w.Result().StatusCode).IsEqualTo(http.StatusUnprocessableEntity)
+}
+
+func TestContextFromJSONMergePatchTypeMismatch(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPatch, "/", bytes.NewBufferString(`{"count":"five"}`))
+	fixture.r.Header.Set("Content-Type", "application/merge-patch+json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	_, passed := fixture.x.FromJSONMergePatch(&testPatchModel{})
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+	test.That(t, fixture.w.Result().StatusCode).IsEqualTo(http.StatusBadRequest)
+}
+
+func TestContextFromJSONMergePatchNotObject(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPatch, "/", bytes.NewBufferString(`["message"]`))
+	fixture.r.Header.Set("Content-Type", "application/merge-patch+json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	patch, passed := fixture.x.FromJSONMergePatch(nil)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+	test.That(t, len(patch)).IsEqualTo(0)
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusBadRequest)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/json/merge-patch-not-object","title":"Merge Patch Not An Object","detail":"The provided merge patch must be a JSON object."}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextFromJSONMergePatchContentTypeIncorrect(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodPatch, "/", bytes.NewBufferString(`{"message":"Goodbye!"}`))
+	fixture.r.Header.Set("Content-Type", "application/json")
+	fixture.x.r = fixture.r
+
+	// Act.
+	_, passed := fixture.x.FromJSONMergePatch(nil)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+	test.That(t, fixture.w.Result().StatusCode).IsEqualTo(http.StatusUnsupportedMediaType)
+}
+
+func TestContextPaginationDefaults(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	page, size, ok := fixture.x.Pagination(20, 100)
+
+	// Assert.
+	test.That(t, ok).IsTrue()
+	test.That(t, page).IsEqualTo(1)
+	test.That(t, size).IsEqualTo(20)
+}
+
+func TestContextPaginationClamps(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodGet, "/?page=-3&pageSize=500", nil)
+	fixture.x.r = fixture.r
+
+	// Act.
+	page, size, ok := fixture.x.Pagination(20, 100)
+
+	// Assert.
+	test.That(t, ok).IsTrue()
+	test.That(t, page).IsEqualTo(1)
+	test.That(t, size).IsEqualTo(100)
+}
+
+func TestContextPaginationInvalid(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodGet, "/?page=two", nil)
+	fixture.x.r = fixture.r
+
+	// Act.
+	_, _, ok := fixture.x.Pagination(20, 100)
+
+	// Assert.
+	test.That(t, ok).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusBadRequest)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/invalid-query-parameter","title":"Invalid Query Parameter","detail":"The value 'two' for the query parameter 'page' is not a valid int.","specifics":{"expectedType":"int","parameter":"page","value":"two"}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextQueryIntoSuccess(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodGet, "/?name=john&limit=25&active=true&tag=a&tag=b&min=1.5", nil)
+	fixture.x.r = fixture.r
+
+	// Act.
+	model := &testQueryModel{}
+	passed := fixture.x.QueryInto(model)
+
+	// Assert.
+	test.That(t, passed).IsTrue()
+	test.That(t, model.Name).IsEqualTo("john")
+	test.That(t, model.Limit).IsEqualTo(25)
+	test.That(t, model.Active).IsTrue()
+	test.That(t, model.Tags).HasEquivalentSequenceTo([]string{"a", "b"})
+	test.That(t, *model.Min).IsEqualTo(1.5)
+	test.That(t, model.Ignored).IsEqualTo("")
+}
+
+func TestContextQueryIntoUnparsable(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodGet, "/?limit=lots", nil)
+	fixture.x.r = fixture.r
+
+	// Act.
+	model := &testQueryModel{}
+	passed := fixture.x.QueryInto(model)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusBadRequest)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/invalid-query-parameter","title":"Invalid Query Parameter","detail":"The value 'lots' for the query parameter 'limit' is not a valid int.","specifics":{"expectedType":"int","parameter":"limit","value":"lots"}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextQueryIntoPurifyFailure(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r = httptest.NewRequest(http.MethodGet, "/?limit=1000", nil)
+	fixture.x.r = fixture.r
+
+	// Act.
+	model := &testQueryModel{}
+	passed := fixture.x.QueryInto(model)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusUnprocessableEntity)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/unprocessable-entity","title":"Unprocessable Entity","detail":"The provided request body was understood but contained some invalid values.","specifics":{"error":"must not exceed 100","errors":[{"error":"must not exceed 100","field":"limit"}],"field":"limit"}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextHeadersIntoSuccess(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r.Header.Set("X-API-Key", "secret")
+	fixture.r.Header.Set("X-Tenant", "acme")
+	fixture.r.Header.Set("X-Version", "2")
+
+	// Act.
+	model := &testHeadersModel{}
+	passed := fixture.x.HeadersInto(model)
+
+	// Assert.
+	test.That(t, passed).IsTrue()
+	test.That(t, model.APIKey).IsEqualTo("secret")
+	test.That(t, model.Tenant).IsEqualTo("acme")
+	test.That(t, model.Version).IsEqualTo(2)
+}
+
+func TestContextHeadersIntoMalformed(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r.Header.Set("X-API-Key", "secret")
+	fixture.r.Header.Set("X-Version", "two")
+
+	// Act.
+	model := &testHeadersModel{}
+	passed := fixture.x.HeadersInto(model)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusBadRequest)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/invalid-header","title":"Invalid Header","detail":"The value 'two' for the header 'X-Version' is not a valid int.","specifics":{"expectedType":"int","header":"X-Version","value":"two"}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextNotFound(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	fixture.x.NotFound("User", "1234")
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusNotFound)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/not-found","title":"Not Found","detail":"The User '1234' was not found.","specifics":{"subject":"1234","subjectType":"User"}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextNotFoundWithCustomTitleAndSlug(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.ProblemTitles = map[int]string{http.StatusNotFound: "Introuvable"}
+	fixture.x.config.ProblemSlugs = map[int]string{http.StatusNotFound: "introuvable"}
+
+	// Act.
+	fixture.x.NotFound("User", "1234")
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusNotFound)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/introuvable","title":"Introuvable","detail":"The User '1234' was not found.","specifics":{"subject":"1234","subjectType":"User"}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextNotFoundWithInstance(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.ProblemDetailsInstance = true
+	fixture.r = httptest.NewRequest(http.MethodGet, "/users/1234", nil)
+	fixture.x.r = fixture.r
+
+	// Act.
+	fixture.x.NotFound("User", "1234")
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusNotFound)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := fmt.Sprintf(`{"type":"https://testi.ng/http/not-found","title":"Not Found","detail":"The User '1234' was not found.","specifics":{"subject":"1234","subjectType":"User"},"instance":"/users/1234#%v"}`, fixture.x.GetCorrelationID())
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextNotFoundWithInstanceWhenCorrelationIDSuppressed(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.ProblemDetailsInstance = true
+	fixture.x.config.SuppressCorrelationIDHeader = true
+	fixture.r = httptest.NewRequest(http.MethodGet, "/users/1234", nil)
+	fixture.x.r = fixture.r
+
+	// Act.
+	fixture.x.NotFound("User", "1234")
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.Header.Get("Correlation-ID")).IsEqualTo("")
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/not-found","title":"Not Found","detail":"The User '1234' was not found.","specifics":{"subject":"1234","subjectType":"User"},"instance":"/users/1234"}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextGone(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	fixture.x.Gone("User", "1234")
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusGone)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/gone","title":"Gone","detail":"The User '1234' is no longer available.","specifics":{"subject":"1234","subjectType":"User"}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextRequestTimeout(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	fixture.x.RequestTimeout("The upstream took too long.")
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusRequestTimeout)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/request-timeout","title":"Request Timeout","detail":"The upstream took too long."}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextPreconditionFailed(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	fixture.x.PreconditionFailed("The resource has been modified.")
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusPreconditionFailed)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/precondition-failed","title":"Precondition Failed","detail":"The resource has been modified."}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextPreconditionRequired(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	fixture.x.PreconditionRequired("This endpoint requires the If-Match header.")
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusPreconditionRequired)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/precondition-required","title":"Precondition Required","detail":"This endpoint requires the If-Match header."}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextInternalServerError(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	fixture.x.InternalServerError(fmt.Errorf("ahhh"))
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusInternalServerError)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/internal-server-error","title":"Internal Server Error","detail":"An internal server error prevented the request from completing.","error":"ahhh"}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextInternalServerErrorSanitizedInProduction(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.DebuggingEnabled = false
+
+	errQuota := fmt.Errorf("quota exceeded for tenant 1234 on shard db-07")
+	fixture.x.config.ErrorSanitizer = func(err error) string {
+		if err == errQuota {
+			return "The quota for this account has been exceeded."
+		}
+
+		return ""
+	}
+
+	// Act.
+	fixture.x.InternalServerError(errQuota)
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusInternalServerError)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/internal-server-error","title":"Internal Server Error","detail":"An internal server error prevented the request from completing.","error":"The quota for this account has been exceeded."}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextInternalServerErrorUnsanitizedHiddenInProduction(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.DebuggingEnabled = false
+	fixture.x.config.ErrorSanitizer = func(err error) string {
+		return ""
+	}
+
+	// Act.
+	fixture.x.InternalServerError(fmt.Errorf("ahhh"))
+
+	// Assert.
+	rawJSON, err := ioutil.ReadAll(fixture.w.Result().Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/internal-server-error","title":"Internal Server Error","detail":"An internal server error prevented the request from completing."}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextFailWithWrappedHTTPError(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	err := fmt.Errorf("loading invoice: %w", &HTTPError{
+		Status:    http.StatusConflict,
+		Slug:      "invoice-locked",
+		Detail:    "The invoice is locked.",
+		Specifics: map[string]interface{}{"invoice": "1234"},
+	})
+
+	// Act.
+	fixture.x.Fail(err)
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusConflict)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/invoice-locked","title":"Conflict","detail":"The invoice is locked.","specifics":{"invoice":"1234"}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextFailWithHTTPErrorPrefersExplicitSlugAndTitle(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.ProblemSlugs = map[int]string{http.StatusConflict: "conflict"}
+	fixture.x.config.ProblemTitles = map[int]string{http.StatusConflict: "Conflict!"}
+
+	// Act.
+	fixture.x.Fail(HTTPError{Status: http.StatusConflict, Slug: "account-locked", Title: "Account Locked"})
+
+	// Assert.
+	rawJSON, err := ioutil.ReadAll(fixture.w.Result().Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/account-locked","title":"Account Locked"}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextFailWithHTTPErrorUsesConfiguredSlugAndTitle(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.x.config.ProblemSlugs = map[int]string{http.StatusConflict: "conflict"}
+	fixture.x.config.ProblemTitles = map[int]string{http.StatusConflict: "Conflict!"}
+
+	// Act.
+	fixture.x.Fail(HTTPError{Status: http.StatusConflict, Detail: "Try again later."})
+
+	// Assert.
+	rawJSON, err := ioutil.ReadAll(fixture.w.Result().Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/conflict","title":"Conflict!","detail":"Try again later."}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextFailWithGenericError(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	fixture.x.Fail(fmt.Errorf("ahhh"))
+
+	// Assert.
+	test.That(t, fixture.w.Result().StatusCode).IsEqualTo(http.StatusInternalServerError)
+}
+
+func TestContextRespondWithRawJSON(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	raw := []byte(`{"message":"Hello, World!"}`)
+
+	// Act.
+	fixture.x.RespondWithRawJSON(http.StatusOK, raw)
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+	test.That(t, res.Header.Get("Content-Type")).IsEqualTo("application/json")
+	test.That(t, res.Header.Get("Content-Length")).IsEqualTo("27")
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+	test.That(t, string(rawJSON)).IsEqualTo(`{"message":"Hello, World!"}`)
+}
+
+func TestContextRespondWithRawJSONInvalidWhenDebugging(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	fixture.x.RespondWithRawJSON(http.StatusOK, []byte(`{"message":`))
+
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusInternalServerError)
+
+	problem := &problem.Details{}
+	err := UnmarshalFromResponse(res, problem)
+	test.That(t, err).IsNil()
+	test.That(t, problem.Error).IsEqualTo("the raw JSON response is not valid JSON")
+}
+
+func TestContextRespondWithCSV(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	headers := []string{"name", "note"}
+	rows := [][]string{
+		{"Alice", "plain"},
+		{"Bob", `says "hi", twice`},
+		{"Carol", "multi\nline"},
+	}
+
+	// Act.
+	fixture.x.RespondWithCSV(http.StatusOK, headers, rows, "report.csv")
+
+	// Assert.
 	res := fixture.w.Result()
-	test.That(t, res.StatusCode).IsEqualTo(http.StatusInternalServerError)
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+	test.That(t, res.Header.Get("Content-Type")).IsEqualTo("text/csv; charset=utf-8")
+	test.That(t, res.Header.Get("Content-Disposition")).IsEqualTo(`attachment; filename="report.csv"`)
 
-	rawJSON, err := ioutil.ReadAll(res.Body)
+	rawCSV, err := ioutil.ReadAll(res.Body)
 	test.That(t, err).IsNil()
-
-	json := string(rawJSON)
-	expectedJSON := "{\"type\":\"https://testi.ng/http/internal-server-error\",\"title\":\"Internal Server Error\",\"detail\":\"An internal server error prevented the request from completing.\",\"error\":\"the type `io.Writer` does not have a resolver in this container\"}"
-	test.That(t, json).IsEqualTo(expectedJSON)
+	test.That(t, string(rawCSV)).IsEqualTo("name,note\nAlice,plain\nBob,\"says \"\"hi\"\", twice\"\nCarol,\"multi\nline\"\n")
 }
 
-func TestContextMiddlewareArtifactsSymmetric(t *testing.T) {
+func TestContextRespondWithCSVByteOrderMark(t *testing.T) {
 	// Arrange.
 	fixture := SetupContextTestFixture()
+	fixture.x.config.CSVByteOrderMark = true
 
 	// Act.
-	fixture.x.SetMiddlewareArtifact("number", 5)
-	number := fixture.x.GetMiddlewareArtifact("number").(int)
+	fixture.x.RespondWithCSV(http.StatusOK, nil, [][]string{{"a", "b"}}, "report.csv")
 
 	// Assert.
-	test.That(t, number).IsEqualTo(5)
+	rawCSV, err := ioutil.ReadAll(fixture.w.Result().Body)
+	test.That(t, err).IsNil()
+	test.That(t, string(rawCSV)).IsEqualTo("\uFEFFa,b\n")
 }
 
-func TestContextSendsCorrelationID(t *testing.T) {
+func TestContextStreamNDJSON(t *testing.T) {
 	// Arrange.
 	fixture := SetupContextTestFixture()
 
 	// Act.
-	fixture.x.Respond(http.StatusOK)
+	nw, err := fixture.x.StreamNDJSON(http.StatusOK)
+	test.That(t, err).IsNil()
+
+	for _, message := range []string{"one", "two", "three"} {
+		err = nw.Write(&testResponseModel{Message: message})
+		test.That(t, err).IsNil()
+	}
 
 	// Assert.
 	res := fixture.w.Result()
 	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
-	test.That(t, res.Header.Get("Correlation-ID")).IsEqualTo(fixture.x.correlationID.String())
+	test.That(t, res.Header.Get("Content-Type")).IsEqualTo("application/x-ndjson")
+
+	rawBody, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+	test.That(t, string(rawBody)).IsEqualTo("{\"message\":\"one\"}\n{\"message\":\"two\"}\n{\"message\":\"three\"}\n")
 }
 
-func TestContextRespondWithJSONUnmarshallable(t *testing.T) {
+func TestContextStreamNDJSONCancelled(t *testing.T) {
 	// Arrange.
 	fixture := SetupContextTestFixture()
+	reqCtx, cancel := context.WithCancel(context.Background())
+	fixture.x.r = fixture.r.WithContext(reqCtx)
+
+	nw, err := fixture.x.StreamNDJSON(http.StatusOK)
+	test.That(t, err).IsNil()
 
 	// Act.
-	fixture.x.RespondWithJSON(http.StatusOK, &testUnmarshallableStruct{})
+	cancel()
+	err = nw.Write(&testResponseModel{Message: "one"})
 
 	// Assert.
-	res := fixture.w.Result()
-	test.That(t, res.StatusCode).IsEqualTo(http.StatusInternalServerError)
+	test.That(t, errors.Is(err, context.Canceled)).IsTrue()
+	test.That(t, fixture.w.Body.Len()).IsEqualTo(0)
+}
 
-	problemDetails := &problem.Details{}
-	err := UnmarshalFromResponse(res, problemDetails)
+func TestContextStreamNDJSONFailureIsLogged(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	logger := logging.NewDummyLogger()
+	fixture.x.logger = logger
+
+	nw, err := fixture.x.StreamNDJSON(http.StatusOK)
 	test.That(t, err).IsNil()
 
-	test.That(t, problemDetails.Type).IsEqualTo("https://testi.ng/http/internal-server-error")
-	test.That(t, problemDetails.Title).IsEqualTo("Internal Server Error")
-	test.That(t, problemDetails.Error).IsNotEqualTo("")
+	err = nw.Write(&testResponseModel{Message: "one"})
+	test.That(t, err).IsNil()
+
+	// Act.
+	err = nw.Write(func() {})
+	nextErr := nw.Write(&testResponseModel{Message: "two"})
+
+	// Assert.
+	test.That(t, err).IsNotNil()
+	test.That(t, nextErr).IsEqualTo(err)
+	test.That(t, fixture.w.Body.String()).IsEqualTo("{\"message\":\"one\"}\n")
+	logger.AssertLogged(t, "! / streaming NDJSON failed after 1 records: json: unsupported type: func()\n")
 }
 
-func TestContextRespondWithJSONSuccess(t *testing.T) {
+func TestContextStreamNDJSONAfterResponding(t *testing.T) {
 	// Arrange.
 	fixture := SetupContextTestFixture()
+	fixture.x.Respond(http.StatusNoContent)
 
 	// Act.
-	fixture.x.RespondWithJSON(http.StatusOK, &testResponseModel{Message: "Hello, World!"})
+	nw, err := fixture.x.StreamNDJSON(http.StatusOK)
 
 	// Assert.
-	res := fixture.w.Result()
-	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
-
-	responseModel := &testResponseModel{}
-	err := UnmarshalFromResponse(res, responseModel)
-	test.That(t, err).IsNil()
-	test.That(t, responseModel.Message).IsEqualTo("Hello, World!")
-	test.That(t, res.Header.Get("Content-Type")).IsEqualTo("application/json")
-	test.That(t, res.Header.Get("Content-Length")).IsEqualTo("27")
+	test.That(t, nw).IsNil()
+	test.That(t, err).IsNotNil()
 }
 
-func TestContextAssertContentTypeSuccess(t *testing.T) {
+func TestContextRequireIfMatchMatching(t *testing.T) {
 	// Arrange.
 	fixture := SetupContextTestFixture()
-	fixture.r.Header.Set("Content-Type", "image/png")
+	fixture.r.Header.Set("If-Match", `"v1", "v2"`)
 
 	// Act.
-	passed := fixture.x.AssertContentType("image/PNG")
+	ok := fixture.x.RequireIfMatch("v2")
 
 	// Assert.
-	test.That(t, passed).IsTrue()
+	test.That(t, ok).IsTrue()
+	test.That(t, fixture.x.ResponseWritten()).IsFalse()
 }
 
-func TestContextAssertContentTypeFailure(t *testing.T) {
+func TestContextRequireIfMatchMismatching(t *testing.T) {
 	// Arrange.
 	fixture := SetupContextTestFixture()
-	fixture.r.Header.Set("Content-Type", "image/jpeg")
+	fixture.r.Header.Set("If-Match", `"v1"`)
 
 	// Act.
-	passed := fixture.x.AssertContentType("image/PNG", "image/gif")
+	ok := fixture.x.RequireIfMatch(`"v2"`)
 
 	// Assert.
-	test.That(t, passed).IsFalse()
+	test.That(t, ok).IsFalse()
 
 	res := fixture.w.Result()
-	test.That(t, res.StatusCode).IsEqualTo(http.StatusUnsupportedMediaType)
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusPreconditionFailed)
 
 	rawJSON, err := ioutil.ReadAll(res.Body)
 	test.That(t, err).IsNil()
 
 	json := string(rawJSON)
-	expectedJSON := `{"type":"https://testi.ng/http/unsupported-media-type","title":"Unsupported Media Type","detail":"The Content-Type 'image/jpeg' is not supported by this endpoint.","specifics":{"allowedContentTypes":["image/PNG","image/gif"],"providedContentType":"image/jpeg"}}`
+	expectedJSON := `{"type":"https://testi.ng/http/precondition-failed","title":"Precondition Failed","detail":"The If-Match header does not match the current ETag of the resource."}`
 	test.That(t, json).IsEqualTo(expectedJSON)
 }
 
-func TestContextAssertContentLengthSuccess(t *testing.T) {
+func TestContextRequireIfMatchWeakETagNeverMatches(t *testing.T) {
 	// Arrange.
 	fixture := SetupContextTestFixture()
-	fixture.r = httptest.NewRequest(http.MethodGet, "/", bytes.NewBufferString("Hello, World!"))
-	fixture.r.Header.Set("Content-Length", "13")
-	fixture.x.r = fixture.r
+	fixture.r.Header.Set("If-Match", `W/"v1"`)
 
 	// Act.
-	passed := fixture.x.AssertContentLength(13)
+	ok := fixture.x.RequireIfMatch(`W/"v1"`)
 
 	// Assert.
-	test.That(t, passed).IsTrue()
+	test.That(t, ok).IsFalse()
+	test.That(t, fixture.w.Result().StatusCode).IsEqualTo(http.StatusPreconditionFailed)
 }
 
-func TestContextAssertContentLengthFailureTooLarge(t *testing.T) {
+func TestContextRequireIfMatchMissingHeader(t *testing.T) {
 	// Arrange.
 	fixture := SetupContextTestFixture()
-	fixture.r = httptest.NewRequest(http.MethodGet, "/", bytes.NewBufferString("Hello, World!"))
-	fixture.r.Header.Set("Content-Length", "13")
-	fixture.x.r = fixture.r
 
 	// Act.
-	passed := fixture.x.AssertContentLength(12)
+	ok := fixture.x.RequireIfMatch(`"v1"`)
 
 	// Assert.
-	test.That(t, passed).IsFalse()
+	test.That(t, ok).IsFalse()
 
 	res := fixture.w.Result()
-	test.That(t, res.StatusCode).IsEqualTo(http.StatusRequestEntityTooLarge)
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusPreconditionRequired)
 
 	rawJSON, err := ioutil.ReadAll(res.Body)
 	test.That(t, err).IsNil()
 
 	json := string(rawJSON)
-	expectedJSON := `{"type":"https://testi.ng/http/request-entity-too-large","title":"Request Entity Too Large","detail":"The provided request entity of length 13.00 B (13 bytes) exceeds the maximum of 12.00 B (12 bytes) on this endpoint.","specifics":{"contentLength":13,"maximumContentLength":12}}`
+	expectedJSON := `{"type":"https://testi.ng/http/precondition-required","title":"Precondition Required","detail":"This request requires the If-Match header."}`
 	test.That(t, json).IsEqualTo(expectedJSON)
 }
 
-func TestContextAssertContentLengthFailureNotProvided(t *testing.T) {
+func TestContextStreamJSONArray(t *testing.T) {
 	// Arrange.
 	fixture := SetupContextTestFixture()
 
 	// Act.
-	passed := fixture.x.AssertContentLength(12)
+	aw, err := fixture.x.StreamJSONArray(http.StatusOK)
+	test.That(t, err).IsNil()
 
-	// Assert.
-	test.That(t, passed).IsFalse()
+	for _, message := range []string{"one", "two", "three"} {
+		err = aw.Write(&testResponseModel{Message: message})
+		test.That(t, err).IsNil()
+	}
+
+	err = aw.Close()
+	test.That(t, err).IsNil()
 
+	// Assert.
 	res := fixture.w.Result()
-	test.That(t, res.StatusCode).IsEqualTo(http.StatusLengthRequired)
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+	test.That(t, res.Header.Get("Content-Type")).IsEqualTo("application/json")
 
-	rawJSON, err := ioutil.ReadAll(res.Body)
+	models := []*testResponseModel{}
+	err = UnmarshalFromResponse(res, &models)
 	test.That(t, err).IsNil()
-
-	json := string(rawJSON)
-	expectedJSON := `{"type":"https://testi.ng/http/length-required","title":"Length Required","detail":"This endpoint requires that the Content-Length header be set to a positive, non-zero value."}`
-	test.That(t, json).IsEqualTo(expectedJSON)
+	test.That(t, len(models)).IsEqualTo(3)
+	test.That(t, models[2].Message).IsEqualTo("three")
 }
 
-func TestContextAssertMethodSuccess(t *testing.T) {
+func TestContextStreamJSONArrayEmpty(t *testing.T) {
 	// Arrange.
 	fixture := SetupContextTestFixture()
 
 	// Act.
-	passed := fixture.x.AssertMethod(http.MethodPost, http.MethodGet)
+	aw, err := fixture.x.StreamJSONArray(http.StatusOK)
+	test.That(t, err).IsNil()
+
+	err = aw.Close()
+	test.That(t, err).IsNil()
 
 	// Assert.
-	test.That(t, passed).IsTrue()
+	test.That(t, fixture.w.Body.String()).IsEqualTo("[]")
 }
 
-func TestContextAssertMethodFailure(t *testing.T) {
+func TestContextStreamJSONArrayFailureLeavesArrayUnclosed(t *testing.T) {
 	// Arrange.
 	fixture := SetupContextTestFixture()
+	logger := logging.NewDummyLogger()
+	fixture.x.logger = logger
+
+	aw, err := fixture.x.StreamJSONArray(http.StatusOK)
+	test.That(t, err).IsNil()
+
+	err = aw.Write(&testResponseModel{Message: "one"})
+	test.That(t, err).IsNil()
 
 	// Act.
-	passed := fixture.x.AssertMethod(http.MethodPost, http.MethodPut)
+	err = aw.Write(func() {})
+	closeErr := aw.Close()
 
 	// Assert.
-	test.That(t, passed).IsFalse()
+	test.That(t, err).IsNotNil()
+	test.That(t, closeErr).IsEqualTo(err)
+	test.That(t, fixture.w.Body.String()).IsEqualTo(`[{"message":"one"}`)
+	logger.AssertLogged(t, "! / streaming the JSON array failed after 1 elements: json: unsupported type: func()\n")
+}
 
-	res := fixture.w.Result()
-	test.That(t, res.StatusCode).IsEqualTo(http.StatusMethodNotAllowed)
+func TestContextRedirect(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
 
-	rawJSON, err := ioutil.ReadAll(res.Body)
-	test.That(t, err).IsNil()
+	// Act.
+	fixture.x.Redirect("/orders/1234", http.StatusSeeOther)
 
-	json := string(rawJSON)
-	expectedJSON := `{"type":"https://testi.ng/http/method-not-allowed","title":"Method Not Allowed","detail":"This endpoint does not allow use of the 'GET' method.","specifics":{"allowedMethods":["POST","PUT"],"methodUsed":"GET"}}`
-	test.That(t, json).IsEqualTo(expectedJSON)
+	// Assert.
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusSeeOther)
+	test.That(t, res.Header.Get("Location")).IsEqualTo("/orders/1234")
+	test.That(t, res.Header.Get("Content-Length")).IsEqualTo("0")
+	test.That(t, res.Header.Get("Correlation-ID")).IsEqualTo(fixture.x.GetCorrelationID().String())
+	test.That(t, fixture.w.Body.Len()).IsEqualTo(0)
 }
 
-func TestContextFromJSONContentTypeIncorrect(t *testing.T) {
+func TestContextAbsoluteURLDirect(t *testing.T) {
 	// Arrange.
 	fixture := SetupContextTestFixture()
-	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":"Hello, World!"}`))
-	fixture.r.Header.Set("Content-Type", "application/not-json")
-	fixture.x.r = fixture.r
+	fixture.r.Host = "api.example.com"
+	fixture.r.Header.Set("X-Forwarded-Proto", "https")
+	fixture.r.Header.Set("X-Forwarded-Host", "spoofed.example.com")
 
 	// Act.
-	reqModel := &testRequestModel{}
-	passed := fixture.x.FromJSON(reqModel)
+	url := fixture.x.AbsoluteURL("/users/1234")
 
 	// Assert.
-	test.That(t, passed).IsFalse()
-
-	res := fixture.w.Result()
-	test.That(t, res.StatusCode).IsEqualTo(http.StatusUnsupportedMediaType)
+	test.That(t, url).IsEqualTo("http://api.example.com/users/1234")
 }
 
-func TestContextFromJSONContentLengthMissing(t *testing.T) {
+func TestContextAbsoluteURLDirectTLS(t *testing.T) {
 	// Arrange.
 	fixture := SetupContextTestFixture()
-	fixture.r = httptest.NewRequest(http.MethodPost, "/", nil)
-	fixture.r.Header.Set("Content-Type", "application/json")
-	fixture.x.r = fixture.r
+	fixture.r.Host = "api.example.com"
+	fixture.r.TLS = &tls.ConnectionState{}
 
 	// Act.
-	reqModel := &testRequestModel{}
-	passed := fixture.x.FromJSON(reqModel)
+	url := fixture.x.AbsoluteURL("users/1234")
 
 	// Assert.
-	test.That(t, passed).IsFalse()
-
-	res := fixture.w.Result()
-	test.That(t, res.StatusCode).IsEqualTo(http.StatusLengthRequired)
+	test.That(t, url).IsEqualTo("https://api.example.com/users/1234")
 }
 
-func TestContextFromJSONInvalidJSON(t *testing.T) {
+func TestContextAbsoluteURLTrustedProxy(t *testing.T) {
 	// Arrange.
 	fixture := SetupContextTestFixture()
-	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":"Hello, World!"`))
-	fixture.r.Header.Set("Content-Type", "application/json")
-	fixture.x.r = fixture.r
+	fixture.x.config.TrustedProxies = []string{"10.0.0.0/8", "192.0.2.1"}
+	fixture.r.Host = "internal:8080"
+	fixture.r.Header.Set("X-Forwarded-Proto", "https, http")
+	fixture.r.Header.Set("X-Forwarded-Host", "api.example.com")
 
 	// Act.
-	reqModel := &testRequestModel{}
-	passed := fixture.x.FromJSON(reqModel)
+	url := fixture.x.AbsoluteURL("/users/1234")
 
 	// Assert.
-	test.That(t, passed).IsFalse()
+	test.That(t, url).IsEqualTo("https://api.example.com/users/1234")
+}
 
-	res := fixture.w.Result()
-	test.That(t, res.StatusCode).IsEqualTo(http.StatusBadRequest)
+func TestContextClientIPDirect(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+	fixture.r.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+	// Act.
+	clientIP := fixture.x.ClientIP()
+
+	// Assert.
+	test.That(t, clientIP).IsEqualTo("192.0.2.1")
 }
 
-func TestContextFromJSONPurifyFailure(t *testing.T) {
+func TestContextClientIPTrustedProxy(t *testing.T) {
 	// Arrange.
 	fixture := SetupContextTestFixture()
-	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":"invalid"}`))
-	fixture.r.Header.Set("Content-Type", "application/json")
-	fixture.x.r = fixture.r
+	fixture.x.config.TrustedProxies = []string{"10.0.0.0/8", "192.0.2.1"}
+	fixture.r.Header.Set("X-Forwarded-For", "198.51.100.4, 203.0.113.7, 10.1.2.3")
 
 	// Act.
-	reqModel := &testRequestModel{}
-	passed := fixture.x.FromJSON(reqModel)
+	clientIP := fixture.x.ClientIP()
 
 	// Assert.
-	test.That(t, passed).IsFalse()
+	test.That(t, clientIP).IsEqualTo("203.0.113.7")
+}
+
+func TestContextTooManyRequests(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	fixture.x.TooManyRequests(time.Millisecond * 1500)
 
+	// Assert.
 	res := fixture.w.Result()
-	test.That(t, res.StatusCode).IsEqualTo(http.StatusUnprocessableEntity)
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusTooManyRequests)
+	test.That(t, res.Header.Get("Retry-After")).IsEqualTo("2")
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/too-many-requests","title":"Too Many Requests","detail":"Too many requests have been made in a short period of time.  Please try again later.","specifics":{"retryAfterSeconds":2,"retryable":true}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
 }
 
-func TestContextFromJSONSuccess(t *testing.T) {
+func TestContextRespondWithRetryableProblem(t *testing.T) {
 	// Arrange.
 	fixture := SetupContextTestFixture()
-	fixture.r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"message":"Hello, World!"}`))
-	fixture.r.Header.Set("Content-Type", "application/json")
-	fixture.x.r = fixture.r
 
 	// Act.
-	reqModel := &testRequestModel{}
-	passed := fixture.x.FromJSON(reqModel)
+	fixture.x.RespondWithRetryableProblem(http.StatusServiceUnavailable, "maintenance", "The service is undergoing maintenance.", time.Minute)
 
 	// Assert.
-	test.That(t, passed).IsTrue()
-	test.That(t, reqModel.Message).IsEqualTo("Hello, World!")
+	res := fixture.w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusServiceUnavailable)
+	test.That(t, res.Header.Get("Retry-After")).IsEqualTo("60")
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/maintenance","title":"Service Unavailable","detail":"The service is undergoing maintenance.","specifics":{"retryAfterSeconds":60,"retryable":true}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
 }
 
-func TestContextNotFound(t *testing.T) {
+func TestContextRespondWithRetryableProblemWithoutRetryAfter(t *testing.T) {
 	// Arrange.
 	fixture := SetupContextTestFixture()
 
 	// Act.
-	fixture.x.NotFound("User", "1234")
+	fixture.x.RespondWithRetryableProblem(http.StatusServiceUnavailable, "maintenance", "The service is undergoing maintenance.", 0)
 
 	// Assert.
 	res := fixture.w.Result()
-	test.That(t, res.StatusCode).IsEqualTo(http.StatusNotFound)
+	test.That(t, res.Header.Get("Retry-After")).IsEqualTo("")
 
 	rawJSON, err := ioutil.ReadAll(res.Body)
 	test.That(t, err).IsNil()
 
 	json := string(rawJSON)
-	expectedJSON := `{"type":"https://testi.ng/http/not-found","title":"Not Found","detail":"The User '1234' was not found.","specifics":{"subject":"1234","subjectType":"User"}}`
+	expectedJSON := `{"type":"https://testi.ng/http/maintenance","title":"Service Unavailable","detail":"The service is undergoing maintenance.","specifics":{"retryable":true}}`
 	test.That(t, json).IsEqualTo(expectedJSON)
 }
 
-func TestContextInternalServerError(t *testing.T) {
+func TestContextInternalServerErrorWithCode(t *testing.T) {
 	// Arrange.
 	fixture := SetupContextTestFixture()
+	fixture.x.config.DebuggingEnabled = false
 
 	// Act.
-	fixture.x.InternalServerError(fmt.Errorf("ahhh"))
+	fixture.x.InternalServerErrorWithCode("payment-provider-unavailable", fmt.Errorf("dial tcp 10.0.0.1:443: i/o timeout"))
 
 	// Assert.
 	res := fixture.w.Result()
@@ -404,7 +2524,23 @@ func TestContextInternalServerError(t *testing.T) {
 	test.That(t, err).IsNil()
 
 	json := string(rawJSON)
-	expectedJSON := `{"type":"https://testi.ng/http/internal-server-error","title":"Internal Server Error","detail":"An internal server error prevented the request from completing.","error":"ahhh"}`
+	expectedJSON := `{"type":"https://testi.ng/http/internal-server-error","title":"Internal Server Error","detail":"An internal server error prevented the request from completing.","specifics":{"code":"payment-provider-unavailable"}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestContextInternalServerErrorWithCodeDebugging(t *testing.T) {
+	// Arrange.
+	fixture := SetupContextTestFixture()
+
+	// Act.
+	fixture.x.InternalServerErrorWithCode("payment-provider-unavailable", fmt.Errorf("ahhh"))
+
+	// Assert.
+	rawJSON, err := ioutil.ReadAll(fixture.w.Result().Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/internal-server-error","title":"Internal Server Error","detail":"An internal server error prevented the request from completing.","specifics":{"code":"payment-provider-unavailable"},"error":"ahhh"}`
 	test.That(t, json).IsEqualTo(expectedJSON)
 }
 
@@ -424,6 +2560,83 @@ func (m *testRequestModel) Purify() (string, error) {
 	return "", nil
 }
 
+type testSchemaRequestModel struct {
+	testRequestModel
+}
+
+var _ SchemaValidatable = &testSchemaRequestModel{}
+
+func (*testSchemaRequestModel) Schema() []byte {
+	return []byte(`{"type":"object","required":["message"]}`)
+}
+
+func testJSONSchemaValidator(schema []byte, document []byte) ([]string, error) {
+	parsedSchema := struct {
+		Required []string `json:"required"`
+	}{}
+
+	err := json.Unmarshal(schema, &parsedSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedDocument := map[string]interface{}{}
+	err = json.Unmarshal(document, &parsedDocument)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaErrors := []string{}
+	for _, property := range parsedSchema.Required {
+		if _, ok := parsedDocument[property]; !ok {
+			schemaErrors = append(schemaErrors, fmt.Sprintf("missing required property '%v'", property))
+		}
+	}
+
+	return schemaErrors, nil
+}
+
+type testPatchModel struct {
+	Message string   `json:"message"`
+	Tags    []string `json:"tags"`
+	Count   int      `json:"count"`
+}
+
+var _ Purifiable = &testPatchModel{}
+
+func (m *testPatchModel) Purify() (string, error) {
+	if m.Message == "invalid" {
+		return "message", fmt.Errorf("cannot be the string 'invalid'")
+	}
+
+	return "", nil
+}
+
+type testQueryModel struct {
+	Name    string   `query:"name"`
+	Limit   int      `url:"limit"`
+	Active  bool     `query:"active"`
+	Tags    []string `query:"tag"`
+	Min     *float64 `query:"min"`
+	Ignored string   `query:"-"`
+}
+
+var _ Purifiable = &testQueryModel{}
+
+func (m *testQueryModel) Purify() (string, error) {
+	if m.Limit > 100 {
+		return "limit", fmt.Errorf("must not exceed 100")
+	}
+
+	return "", nil
+}
+
+type testHeadersModel struct {
+	APIKey  string `header:"X-API-Key"`
+	Tenant  string `header:"x-tenant"`
+	Version int    `header:"X-Version"`
+}
+
 type testResponseModel struct {
 	Message string `json:"message"`
 }
@@ -447,3 +2660,26 @@ var _ testInterface = &testStruct{}
 func (*testStruct) Greeting() string {
 	return "Hello, World!"
 }
+
+type testSlowReader struct {
+	delay time.Duration
+}
+
+func (r *testSlowReader) Read(p []byte) (int, error) {
+	time.Sleep(r.delay)
+	return 0, io.EOF
+}
+
+type testRequestAwareService interface {
+	CorrelationID() id.ID
+}
+
+type testRequestAwareStruct struct {
+	ctx *Context
+}
+
+var _ testRequestAwareService = &testRequestAwareStruct{}
+
+func (s *testRequestAwareStruct) CorrelationID() id.ID {
+	return s.ctx.GetCorrelationID()
+}
@@ -0,0 +1,79 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const deadlineMiddlewareArtifactName = "web.deadline"
+
+// DeadlineMiddleware is a Middleware that applies the timeout requested by the
+// client in the X-Timeout-Ms header to the context of the request, capped to a
+// configured maximum.  Handlers that respect the cancellation of the request
+// context will then stop once the deadline passes.  Requests without the
+// header are passed through untouched.
+type DeadlineMiddleware struct {
+	max time.Duration
+}
+
+var _ AfterMiddleware = &DeadlineMiddleware{}
+
+// NewDeadlineMiddleware creates a new DeadlineMiddleware that permits client
+// timeouts of up to max.  There is no way to disable the cap, so max must be
+// positive, or NewDeadlineMiddleware will panic.
+func NewDeadlineMiddleware(max time.Duration) *DeadlineMiddleware {
+	if max <= 0 {
+		panic(fmt.Sprintf("the maximum timeout of a DeadlineMiddleware must be positive, but was %v", max))
+	}
+
+	return &DeadlineMiddleware{
+		max: max,
+	}
+}
+
+// Handle replaces the request with one carrying a deadline derived from the
+// X-Timeout-Ms header.  If the header is not a positive integer, a BadRequest
+// response is sent and false is returned.
+func (m *DeadlineMiddleware) Handle(ctx *Context) bool {
+	rawTimeout := strings.TrimSpace(ctx.r.Header.Get("X-Timeout-Ms"))
+	if rawTimeout == "" {
+		return true
+	}
+
+	timeoutMs, err := strconv.ParseInt(rawTimeout, 10, 64)
+	if err != nil || timeoutMs <= 0 {
+		problem := ctx.getProblemDetailsForInvalidHeader(&fieldBindingError{
+			name:     "X-Timeout-Ms",
+			value:    rawTimeout,
+			typeName: "positive integer",
+			err:      err,
+		})
+
+		ctx.respondWithProblem(http.StatusBadRequest, problem)
+		return false
+	}
+
+	// The maximum is compared in milliseconds, as converting a large timeout
+	// to a time.Duration would overflow.
+	timeout := m.max
+	if timeoutMs < int64(m.max/time.Millisecond) {
+		timeout = time.Duration(timeoutMs) * time.Millisecond
+	}
+
+	requestContext, cancel := context.WithTimeout(ctx.r.Context(), timeout)
+	ctx.r = ctx.r.WithContext(requestContext)
+	ctx.SetMiddlewareArtifact(deadlineMiddlewareArtifactName, cancel)
+
+	return true
+}
+
+// After releases the resources associated with the deadline.
+func (m *DeadlineMiddleware) After(ctx *Context) {
+	if cancel, ok := ctx.GetMiddlewareArtifact(deadlineMiddlewareArtifactName).(context.CancelFunc); ok {
+		cancel()
+	}
+}
@@ -0,0 +1,164 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ljpx/di"
+	"github.com/ljpx/logging"
+	"github.com/ljpx/test"
+)
+
+type DeadlineMiddlewareFixture struct {
+	x       *DeadlineMiddleware
+	handler http.Handler
+}
+
+func SetupDeadlineMiddlewareFixture() *DeadlineMiddlewareFixture {
+	fixture := &DeadlineMiddlewareFixture{}
+	fixture.x = NewDeadlineMiddleware(time.Second)
+
+	builder := NewHandlerBuilder(di.NewContainer(), logging.NewDummyLogger(), &Config{
+		ProblemDetailsTypePrefix: "https://testi.ng",
+	})
+
+	builder.Use(&testSlowRoute{middleware: fixture.x})
+	fixture.handler = builder.Build()
+
+	return fixture
+}
+
+func (fixture *DeadlineMiddlewareFixture) get(timeoutMs string) *http.Response {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/slow/1", nil)
+	if timeoutMs != "" {
+		r.Header.Set("X-Timeout-Ms", timeoutMs)
+	}
+
+	fixture.handler.ServeHTTP(w, r)
+
+	return w.Result()
+}
+
+func TestDeadlineMiddlewareCancelsSlowHandler(t *testing.T) {
+	// Arrange.
+	fixture := SetupDeadlineMiddlewareFixture()
+
+	// Act.
+	res := fixture.get("10")
+
+	// Assert.
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusGatewayTimeout)
+}
+
+func TestDeadlineMiddlewareWithoutHeader(t *testing.T) {
+	// Arrange.
+	fixture := SetupDeadlineMiddlewareFixture()
+	fixture.x.max = time.Millisecond
+
+	// Act.
+	res := fixture.get("")
+
+	// Assert.
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+}
+
+func TestDeadlineMiddlewareCapsToMaximum(t *testing.T) {
+	// Arrange.
+	fixture := SetupDeadlineMiddlewareFixture()
+	fixture.x.max = 10 * time.Millisecond
+
+	// Act.
+	res := fixture.get("60000")
+
+	// Assert.
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusGatewayTimeout)
+}
+
+func TestDeadlineMiddlewareCapsHugeTimeoutToMaximum(t *testing.T) {
+	// Arrange.
+	fixture := SetupDeadlineMiddlewareFixture()
+
+	// Act.
+	res := fixture.get("9223372036854775")
+
+	// Assert.
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+}
+
+func TestDeadlineMiddlewareNonPositiveHeader(t *testing.T) {
+	// Arrange.
+	fixture := SetupDeadlineMiddlewareFixture()
+
+	// Act.
+	zero := fixture.get("0")
+	negative := fixture.get("-5")
+
+	// Assert.
+	test.That(t, zero.StatusCode).IsEqualTo(http.StatusBadRequest)
+	test.That(t, negative.StatusCode).IsEqualTo(http.StatusBadRequest)
+}
+
+func TestDeadlineMiddlewareInvalidHeader(t *testing.T) {
+	// Arrange.
+	fixture := SetupDeadlineMiddlewareFixture()
+
+	// Act.
+	res := fixture.get("soon")
+
+	// Assert.
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusBadRequest)
+}
+
+func TestNewDeadlineMiddlewareRejectsNonPositiveMaximum(t *testing.T) {
+	// Arrange.
+	var recovered interface{}
+
+	// Act.
+	func() {
+		defer func() {
+			recovered = recover()
+		}()
+
+		NewDeadlineMiddleware(0)
+	}()
+
+	// Assert.
+	test.That(t, recovered).IsEqualTo(interface{}("the maximum timeout of a DeadlineMiddleware must be positive, but was 0s"))
+}
+
+// -----------------------------------------------------------------------------
+
+type testSlowRoute struct {
+	middleware Middleware
+}
+
+var _ Route = &testSlowRoute{}
+
+func (*testSlowRoute) Method() string {
+	return http.MethodGet
+}
+
+func (*testSlowRoute) Path() string {
+	return "/slow/{id}"
+}
+
+func (route *testSlowRoute) Middleware() []Middleware {
+	return []Middleware{route.middleware}
+}
+
+func (*testSlowRoute) Handle(ctx *Context) {
+	if ctx.GetPathParameter("id") != "1" {
+		ctx.NotFound("id", ctx.GetPathParameter("id"))
+		return
+	}
+
+	select {
+	case <-ctx.Request().Context().Done():
+		ctx.Respond(http.StatusGatewayTimeout)
+	case <-time.After(50 * time.Millisecond):
+		ctx.Respond(http.StatusOK)
+	}
+}
@@ -0,0 +1,13 @@
+package web
+
+// DocumentedRoute is an optional interface that a Route can implement to
+// describe itself in the document generated by HandlerBuilder.OpenAPISpec.
+// RequestModel and ResponseModel should return zero values of the models the
+// route accepts and responds with, from which JSON schemas are derived.  Either
+// may return nil if the route has no body.
+type DocumentedRoute interface {
+	Route
+	Summary() string
+	RequestModel() interface{}
+	ResponseModel() interface{}
+}
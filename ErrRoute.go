@@ -0,0 +1,71 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/ljpx/logging"
+)
+
+// ErrRoute is an alternative to Route for handlers that return an error rather
+// than writing every response themselves.  ErrRoutes are registered with
+// HandlerBuilder.UseErrRoute.  A returned error is passed to Context.Fail, so
+// an HTTPError results in the matching problem and any other error results in
+// an InternalServerError.  If the handler has already written a response, a
+// returned error is logged instead.  If nil is returned and no response has been
+// written, the request is responded to with http.StatusNoContent.  ErrRoutes
+// may implement the methods of the optional route interfaces, such as Name for
+// NamedRoute, and they are honoured as they are for a Route.
+type ErrRoute interface {
+	Method() string
+	Path() string
+	Middleware() []Middleware
+	Handle(ctx *Context) error
+}
+
+// errRouteAdapter adapts an ErrRoute to a Route.  The adapter only implements
+// Route, so the optional route interfaces are checked against the ErrRoute it
+// wraps, as returned by routeExtension.
+type errRouteAdapter struct {
+	route  ErrRoute
+	logger logging.Logger
+}
+
+var _ Route = &errRouteAdapter{}
+
+func (a *errRouteAdapter) Method() string {
+	return a.route.Method()
+}
+
+func (a *errRouteAdapter) Path() string {
+	return a.route.Path()
+}
+
+func (a *errRouteAdapter) Middleware() []Middleware {
+	return a.route.Middleware()
+}
+
+func (a *errRouteAdapter) Handle(ctx *Context) {
+	err := a.route.Handle(ctx)
+	if err != nil && ctx.ResponseWritten() {
+		resolveRequestLogger(ctx, a.logger).Printf("! %v %v\n", ctx.r.URL.Path, err)
+		return
+	} else if err != nil {
+		ctx.Fail(err)
+		return
+	}
+
+	if !ctx.ResponseWritten() {
+		ctx.Respond(http.StatusNoContent)
+	}
+}
+
+// routeExtension returns the value that should be checked for the methods of the
+// optional route interfaces, such as ConstrainedRoute.  This is the route
+// itself, or the wrapped ErrRoute for a route registered with UseErrRoute.
+func routeExtension(route Route) interface{} {
+	if adapter, ok := route.(*errRouteAdapter); ok {
+		return adapter.route
+	}
+
+	return route
+}
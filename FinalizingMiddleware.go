@@ -0,0 +1,13 @@
+package web
+
+// FinalizingMiddleware is an optional interface that a Middleware can implement
+// to modify the response once the route handler has finished, but before it is
+// written.  When a route has any FinalizingMiddleware, its response is
+// buffered, and Finalize is called with the status code of the response in the
+// reverse order to Handle.  Finalize is only called for middleware whose Handle
+// method returned true, and is called before After.  Headers set in Finalize
+// only take effect if the handler has not flushed the response buffer itself.
+type FinalizingMiddleware interface {
+	Middleware
+	Finalize(ctx *Context, statusCode int)
+}
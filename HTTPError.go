@@ -0,0 +1,57 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// HTTPError is an error that describes the problem response it should result
+// in.  It allows code without access to the Context to signal HTTP semantics.
+// An HTTPError passed to Context.Fail, or with which a route handler panics, is
+// translated into a problem response with the provided status code.  If Slug
+// or Title are empty, they are derived from the status code, using
+// Config.ProblemSlugs and Config.ProblemTitles where they are set.  A Status of
+// zero is treated as http.StatusInternalServerError.
+type HTTPError struct {
+	Status    int
+	Slug      string
+	Title     string
+	Detail    string
+	Specifics map[string]interface{}
+}
+
+var _ error = HTTPError{}
+
+// Error returns a human-readable description of the error.
+func (e HTTPError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("%v %v", e.status(), http.StatusText(e.status()))
+	}
+
+	return fmt.Sprintf("%v %v: %v", e.status(), http.StatusText(e.status()), e.Detail)
+}
+
+func (e HTTPError) status() int {
+	if e.Status == 0 {
+		return http.StatusInternalServerError
+	}
+
+	return e.Status
+}
+
+// asHTTPError returns the first HTTPError in the chain of the provided error,
+// whether it was provided as a value or a pointer.
+func asHTTPError(err error) (HTTPError, bool) {
+	var httpErr HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr, true
+	}
+
+	var httpErrPtr *HTTPError
+	if errors.As(err, &httpErrPtr) && httpErrPtr != nil {
+		return *httpErrPtr, true
+	}
+
+	return HTTPError{}, false
+}
@@ -1,9 +1,13 @@
 package web
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/ljpx/di"
@@ -12,14 +16,23 @@ import (
 
 // HandlerBuilder is used to build a handler that can be passed to any HTTP
 // server.  Once Build has been called, the HandlerBuilder is invalid and can
-// no longer be used.  HandlerBuilder is not thread-safe.
+// no longer be used, with the exception of URLFor and Conflicts.  HandlerBuilder
+// is not thread-safe.
 type HandlerBuilder struct {
 	c      di.Container
 	config *Config
 	logger logging.Logger
 
-	routesByPath map[string][]Route
-	hasBeenBuilt bool
+	routesByPath         map[string][]Route
+	hostBuilders         map[string]*HandlerBuilder
+	namedRoutes          *mux.Router
+	namedMiddleware      map[string]Middleware
+	namedMiddlewareOrder []string
+	globalMiddleware     []Middleware
+	httpMiddleware       []func(http.Handler) http.Handler
+	parent               *HandlerBuilder
+	host                 string
+	hasBeenBuilt         bool
 }
 
 // NewHandlerBuilder creates a new handler builder with the provided config and
@@ -30,76 +43,593 @@ func NewHandlerBuilder(c di.Container, logger logging.Logger, config *Config) *H
 		config: config,
 		logger: logger,
 
-		routesByPath: make(map[string][]Route),
+		routesByPath:    make(map[string][]Route),
+		hostBuilders:    make(map[string]*HandlerBuilder),
+		namedRoutes:     mux.NewRouter(),
+		namedMiddleware: make(map[string]Middleware),
 	}
 }
 
+// Host returns a sub-builder whose routes will only match requests with the
+// provided Host header.  The host may contain variables (e.g.
+// {tenant}.example.com), which are made available through GetPathParameter.
+// Calling Host on a sub-builder, or calling Build on a sub-builder, will panic.
+func (b *HandlerBuilder) Host(host string) *HandlerBuilder {
+	b.assertNotAlreadyBuilt()
+	b.assertNotHostBuilder()
+
+	hostBuilder, ok := b.hostBuilders[host]
+	if !ok {
+		hostBuilder = NewHandlerBuilder(b.c, b.logger, b.config)
+		hostBuilder.parent = b
+		hostBuilder.host = host
+		hostBuilder.namedRoutes = b.namedRoutes
+		b.hostBuilders[host] = hostBuilder
+	}
+
+	return hostBuilder
+}
+
 // Use adds a route to the list of routes this handler should expose.
 func (b *HandlerBuilder) Use(route Route) {
 	b.assertNotAlreadyBuilt()
 
 	path := purifyPath(route.Path())
+	if constrainedRoute, ok := routeExtension(route).(interface{ Constraints() map[string]string }); ok {
+		path = constrainPath(path, constrainedRoute.Constraints())
+	}
+
+	if namedRoute, ok := routeExtension(route).(interface{ Name() string }); ok {
+		b.name(namedRoute.Name(), path)
+	}
+
 	b.routesByPath[path] = append(b.routesByPath[path], route)
 }
 
-// Build builds a http.Handler that can be passed to any server.
+// UseErrRoute adds an ErrRoute to the list of routes this handler should
+// expose.
+func (b *HandlerBuilder) UseErrRoute(route ErrRoute) {
+	b.Use(&errRouteAdapter{route: route, logger: b.logger})
+}
+
+// UseAll adds each of the provided routes, in order, to the list of routes
+// this handler should expose.  A slice of routes can be passed with routes...
+func (b *HandlerBuilder) UseAll(routes ...Route) {
+	for _, route := range routes {
+		b.Use(route)
+	}
+}
+
+// UseNamedMiddleware registers a single, shared instance of a middleware under
+// the provided name.  Routes implementing NamedMiddlewareRoute can reference the
+// middleware by name, and named middleware always run in the order in which
+// they were registered.  Calling UseNamedMiddleware on a builder returned by
+// Host registers the middleware with the parent builder.
+func (b *HandlerBuilder) UseNamedMiddleware(name string, mw Middleware) {
+	b.assertNotAlreadyBuilt()
+
+	root := b.root()
+	if _, ok := root.namedMiddleware[name]; ok {
+		panic(fmt.Sprintf("a middleware with the name '%v' has already been registered", name))
+	}
+
+	root.namedMiddleware[name] = mw
+	root.namedMiddlewareOrder = append(root.namedMiddlewareOrder, name)
+}
+
+// UseGlobalMiddleware adds a middleware that is run for every request, before
+// any named or route middleware.  Global middleware are also run for requests
+// that do not match a route, or that use a method the matched path does not
+// support.  Calling UseGlobalMiddleware on a builder returned by Host registers
+// the middleware with the parent builder.
+func (b *HandlerBuilder) UseGlobalMiddleware(mw Middleware) {
+	b.assertNotAlreadyBuilt()
+
+	root := b.root()
+	root.globalMiddleware = append(root.globalMiddleware, mw)
+}
+
+// UseHTTPMiddleware adds a standard library style middleware that wraps the
+// built handler.  HTTP middleware run before routing, method overriding and
+// any Middleware, so they see every request as it was received.  The first
+// HTTP middleware added is the outermost.
+func (b *HandlerBuilder) UseHTTPMiddleware(mw func(http.Handler) http.Handler) {
+	b.assertNotAlreadyBuilt()
+	b.assertNotHostBuilder()
+
+	b.httpMiddleware = append(b.httpMiddleware, mw)
+}
+
+// URLFor generates the URL for the NamedRoute with the provided name,
+// substituting the provided key/value pairs into the path parameters of the
+// route.  If the route was registered through a builder returned by Host, the
+// resulting URL will be absolute.
+func (b *HandlerBuilder) URLFor(name string, pairs ...string) (string, error) {
+	namedRoute := b.namedRoutes.Get(name)
+	if namedRoute == nil {
+		return "", fmt.Errorf("no route with the name '%v' has been registered", name)
+	}
+
+	u, err := namedRoute.URL(pairs...)
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// AllowedMethods returns the sorted methods supported by the routes registered
+// with the provided path, which can be called before or after Build.  The path
+// is matched against the paths of the routes as they were provided, so any
+// constraints of a ConstrainedRoute need not be included.  The routes of the
+// builders returned by Host are included, so the methods of a single host are
+// found by calling AllowedMethods on its builder instead.  If no routes are
+// registered with the path, an empty slice is returned.
+func (b *HandlerBuilder) AllowedMethods(path string) []string {
+	path = purifyPath(path)
+
+	builders := []*HandlerBuilder{b}
+	for _, host := range b.sortedHosts() {
+		builders = append(builders, b.hostBuilders[host])
+	}
+
+	routes := []Route{}
+	for _, builder := range builders {
+		for registeredPath, registeredRoutes := range builder.routesByPath {
+			for _, route := range registeredRoutes {
+				if registeredPath == path || purifyPath(route.Path()) == path {
+					routes = append(routes, route)
+				}
+			}
+		}
+	}
+
+	return b.allowedMethods(routes)
+}
+
+// OpenAPISpec generates a minimal OpenAPI 3 document describing the registered
+// routes, including those registered through builders returned by Host.  Each
+// route is described by its path, method and path parameters, and routes
+// implementing DocumentedRoute also include a summary and the JSON schemas of
+// their request and response models.  The title and version of the document
+// are placeholders, and should be replaced by the caller if required.  A
+// single document cannot describe the same path and method on different hosts,
+// so an error is returned if two hosts, or a host and the root builder, both
+// register a route for the same path and method.
+func (b *HandlerBuilder) OpenAPISpec() ([]byte, error) {
+	paths := map[string]interface{}{}
+	hostsByOperation := map[string]string{}
+
+	builders := []*HandlerBuilder{b}
+	for _, host := range b.sortedHosts() {
+		builders = append(builders, b.hostBuilders[host])
+	}
+
+	for _, builder := range builders {
+		for path, routes := range builder.routesByPath {
+			openAPIPath, parameters := openAPIPath(b.basePath() + path)
+
+			pathItem, ok := paths[openAPIPath].(map[string]interface{})
+			if !ok {
+				pathItem = map[string]interface{}{}
+				paths[openAPIPath] = pathItem
+			}
+
+			for _, route := range routes {
+				operation := fmt.Sprintf("%v %v", route.Method(), openAPIPath)
+				if host, ok := hostsByOperation[operation]; ok {
+					return nil, fmt.Errorf("the OpenAPI operation %v is registered for both %v and %v", operation, describeHost(host), describeHost(builder.host))
+				}
+
+				hostsByOperation[operation] = builder.host
+				pathItem[strings.ToLower(route.Method())] = openAPIOperation(route, parameters)
+			}
+		}
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+	})
+}
+
+// Conflicts returns the pairs of route paths that can match the same request
+// path, including those registered through builders returned by Host.
+func (b *HandlerBuilder) Conflicts() []Conflict {
+	conflicts := b.pathConflicts()
+
+	hosts := b.sortedHosts()
+	for _, host := range hosts {
+		conflicts = append(conflicts, b.hostBuilders[host].pathConflicts()...)
+	}
+
+	return conflicts
+}
+
+// Build builds a http.Handler that can be passed to any server.  If
+// Config.RouteConflictHandler is set, it is called for each of the Conflicts.
+// If Config.BasePath is set, all routes are exposed beneath it.  If
+// Config.AllowMethodOverride is set, POST requests can be handled as PUT, PATCH
+// or DELETE requests using the X-HTTP-Method-Override header.  If
+// Config.AutoHead is set, HEAD requests to paths without a HEAD route are
+// handled by the GET route, with the response body discarded.
 func (b *HandlerBuilder) Build() http.Handler {
+	return b.BuildInto(mux.NewRouter())
+}
+
+// BuildInto behaves like Build, but registers the routes onto the provided
+// router rather than a new one.  A catch-all route for unmatched requests is
+// registered last, so any routes added to the router after BuildInto will never
+// be matched.
+func (b *HandlerBuilder) BuildInto(mx *mux.Router) http.Handler {
 	b.assertNotAlreadyBuilt()
+	b.assertNotHostBuilder()
 	b.hasBeenBuilt = true
 
-	mx := mux.NewRouter()
+	if b.config.RouteConflictHandler != nil {
+		for _, conflict := range b.Conflicts() {
+			b.config.RouteConflictHandler(conflict)
+		}
+	}
+
+	basePath := b.basePath()
+	for _, host := range b.sortedHosts() {
+		b.hostBuilders[host].registerRoutes(mx.Host(host).PathPrefix(basePath).Subrouter())
+	}
+
+	b.registerRoutes(mx.PathPrefix(basePath).Subrouter())
+
+	var notFoundRoute *mux.Route
+	notFoundRequestHandler := buildHandlerFromRequest(b.c, b.logger, b.config, buildHandlerWithMiddleware(b.globalMiddleware, func(ctx *Context) {
+		path := ctx.r.URL.Path
+		if b.config.RedirectTrailingSlash && len(path) > 1 && strings.HasSuffix(path, "/") {
+			trimmedPath := strings.TrimRight(path, "/")
+			if matchesRoute(mx, ctx.r, trimmedPath, notFoundRoute) {
+				ctx.redirectToPath(trimmedPath, trailingSlashRedirectCode(ctx.r.Method))
+				return
+			}
+		}
+
+		ctx.NotFound("path", path)
+	}))
+
+	notFoundRoute = mx.PathPrefix("/").HandlerFunc(notFoundRequestHandler)
+
+	var handler http.Handler = mx
+	if b.config.AllowMethodOverride {
+		handler = overrideMethod(handler)
+	}
+
+	for i := len(b.httpMiddleware) - 1; i >= 0; i-- {
+		handler = b.httpMiddleware[i](handler)
+	}
+
+	return handler
+}
+
+// matchesRoute returns true if the provided request would be routed to a route
+// other than notFoundRoute if it had the provided path.
+func matchesRoute(mx *mux.Router, r *http.Request, path string, notFoundRoute *mux.Route) bool {
+	if path == "" {
+		path = "/"
+	}
+
+	u := *r.URL
+	u.Path = path
+	u.RawPath = ""
+
+	r = r.WithContext(r.Context())
+	r.URL = &u
+
+	match := &mux.RouteMatch{}
+	return mx.Match(r, match) && match.Route != nil && match.Route != notFoundRoute
+}
+
+// trailingSlashRedirectCode returns the status code used to redirect a request
+// with the provided method to the path without a trailing slash.  Clients may
+// change the method of a request to GET when following a 301, so methods other
+// than GET and HEAD are redirected with a 308.
+func trailingSlashRedirectCode(method string) int {
+	if method == http.MethodGet || method == http.MethodHead {
+		return http.StatusMovedPermanently
+	}
+
+	return http.StatusPermanentRedirect
+}
 
-	for path, routes := range b.routesByPath {
-		ctxHandler := buildHandlerForPath(path, routes)
+// overrideMethod wraps the provided handler so that POST requests with an
+// X-HTTP-Method-Override header of PUT, PATCH or DELETE are handled as requests
+// with that method.
+func overrideMethod(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			switch override := strings.ToUpper(strings.TrimSpace(r.Header.Get("X-HTTP-Method-Override"))); override {
+			case http.MethodPut, http.MethodPatch, http.MethodDelete:
+				// The request is copied so that the caller, such as an outer
+				// http middleware, still sees the original method.
+				r2 := new(http.Request)
+				*r2 = *r
+				r2.Method = override
+				r = r2
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (b *HandlerBuilder) name(name string, path string) {
+	if b.namedRoutes.Get(name) != nil {
+		panic(fmt.Sprintf("a route with the name '%v' has already been registered", name))
+	}
+
+	namedRoute := b.namedRoutes.NewRoute()
+	if b.host != "" {
+		namedRoute = namedRoute.Host(b.host)
+	}
+
+	namedRoute.Path(b.basePath() + path).Name(name)
+}
+
+// basePath returns Config.BasePath without any trailing slashes, so that it can
+// be prepended to the paths of routes.
+func (b *HandlerBuilder) basePath() string {
+	return strings.TrimRight(purifyPath(b.config.BasePath), "/")
+}
+
+func (b *HandlerBuilder) registerRoutes(mx *mux.Router) {
+	mx.StrictSlash(b.config.StrictSlash)
+
+	for _, path := range b.sortedPaths() {
+		routes := b.routesByPath[path]
+		ctxHandler := buildHandlerWithMiddleware(b.root().globalMiddleware, b.buildHandlerForPath(path, routes))
 		requestHandler := buildHandlerFromRequest(b.c, b.logger, b.config, ctxHandler)
 		mx.HandleFunc(path, requestHandler)
+
+		// A route at the root of Config.BasePath also serves the base path
+		// itself, so that /api is not a 404 when /api/ is not.
+		if path == "/" && b.basePath() != "" {
+			mx.HandleFunc("", requestHandler)
+		}
 	}
+}
 
-	notFoundRequestHandler := buildHandlerFromRequest(b.c, b.logger, b.config, func(ctx *Context) {
-		ctx.NotFound("path", ctx.r.URL.Path)
-	})
+func (b *HandlerBuilder) pathConflicts() []Conflict {
+	conflicts := []Conflict{}
+	paths := b.sortedPaths()
+
+	for i := 0; i < len(paths); i++ {
+		for j := i + 1; j < len(paths); j++ {
+			if templatesOverlap(paths[i], paths[j], "/") {
+				conflicts = append(conflicts, Conflict{
+					Host:      b.host,
+					Preferred: paths[i],
+					Shadowed:  paths[j],
+				})
+			}
+		}
+	}
+
+	return conflicts
+}
+
+func (b *HandlerBuilder) sortedPaths() []string {
+	paths := []string{}
+	for path := range b.routesByPath {
+		paths = append(paths, path)
+	}
+
+	sortTemplatesBySpecificity(paths, "/")
+	return paths
+}
+
+func (b *HandlerBuilder) sortedHosts() []string {
+	hosts := []string{}
+	for host := range b.hostBuilders {
+		hosts = append(hosts, host)
+	}
+
+	sortTemplatesBySpecificity(hosts, ".")
+	return hosts
+}
+
+func (b *HandlerBuilder) resolveNamedMiddleware(route Route) []Middleware {
+	namedMiddlewareRoute, ok := routeExtension(route).(interface{ NamedMiddleware() []string })
+	if !ok {
+		return nil
+	}
+
+	root := b.root()
+	referenced := make(map[string]bool)
+
+	for _, name := range namedMiddlewareRoute.NamedMiddleware() {
+		if _, ok := root.namedMiddleware[name]; !ok {
+			panic(fmt.Sprintf("the route '%v %v' references the unregistered middleware '%v'", route.Method(), route.Path(), name))
+		}
+
+		referenced[name] = true
+	}
 
-	mx.PathPrefix("/").HandlerFunc(notFoundRequestHandler)
+	resolved := []Middleware{}
+	for _, name := range root.namedMiddlewareOrder {
+		if referenced[name] {
+			resolved = append(resolved, root.namedMiddleware[name])
+		}
+	}
 
-	return mx
+	return resolved
+}
+
+func (b *HandlerBuilder) root() *HandlerBuilder {
+	if b.parent != nil {
+		return b.parent
+	}
+
+	return b
 }
 
 func (b *HandlerBuilder) assertNotAlreadyBuilt() {
-	if b.hasBeenBuilt {
+	if b.hasBeenBuilt || (b.parent != nil && b.parent.hasBeenBuilt) {
 		panic("a HandlerBuilder can not be used after Build has been called")
 	}
 }
 
+func (b *HandlerBuilder) assertNotHostBuilder() {
+	if b.parent != nil {
+		panic("this operation is not supported on a HandlerBuilder returned by Host")
+	}
+}
+
 func buildHandlerFromRequest(c di.Container, logger logging.Logger, config *Config, ctxHandler ContextHandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		mrw := NewMeasuredResponseWriter(w)
+		mrw := AcquireMeasuredResponseWriter(w)
+		for key, values := range config.DefaultHeaders {
+			mrw.Header()[key] = append([]string(nil), values...)
+		}
+
 		ctx := NewContext(mrw, r, c, config)
+		ctx.logger = logger
 
+		defer ReleaseMeasuredResponseWriter(mrw)
 		defer func() {
-			if p := recover(); p != nil && !mrw.HasWrittenHeaders() {
-				err := fmt.Errorf("%v", p)
-				ctx.InternalServerError(err)
+			if config.EmitServerTiming {
+				emitServerTiming(ctx, mrw, logger)
 			}
 
-			logmsg := fmt.Sprintf("• %v %v %v %v\n", mrw.statusCode, mrw.Duration(), ByteSizeToFriendlyString(mrw.volume), r.URL.Path)
-			logger.Printf(logmsg)
+			ctx.flushResponseBuffer()
+			ctx.runCompletionHooks()
+
+			logmsg := fmt.Sprintf("• %v %v %v %v %v\n", mrw.statusCode, loggedDuration(mrw, config.DurationUnit), ByteSizeToFriendlyString(mrw.volume), r.URL.Path, ctx.correlationID)
+			resolveRequestLogger(ctx, logger).Printf(logmsg)
 		}()
 
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+
+			err, ok := p.(error)
+			if !ok {
+				err = fmt.Errorf("%v", p)
+			}
+
+			// HTTPError panics are an intended way of responding, so they are
+			// translated even when panic recovery is disabled.
+			if _, ok := asHTTPError(err); ok {
+				if !mrw.HasWrittenHeaders() {
+					ctx.discardResponseBuffer()
+					ctx.Fail(err)
+				}
+
+				return
+			}
+
+			if config.DisablePanicRecovery {
+				panic(p)
+			}
+
+			resolveRequestLogger(ctx, logger).Printf("! %v %v\n", r.URL.Path, err)
+			if config.OnPanic != nil {
+				config.OnPanic(ctx, err)
+			}
+
+			if !mrw.HasWrittenHeaders() {
+				ctx.discardResponseBuffer()
+				ctx.InternalServerError(err)
+			}
+		}()
+
+		if !assertHeaderCount(ctx, config.MaxHeaderCount) {
+			return
+		}
+
 		ctxHandler(ctx)
 	}
 }
 
-func buildHandlerForPath(path string, routes []Route) ContextHandlerFunc {
-	handlerByMethod := make(map[string]ContextHandlerFunc)
-	allowedMethods := []string{}
+// assertHeaderCount responds with a RequestHeaderFieldsTooLarge problem if the
+// request has more than max header values.  A max of zero disables the check.
+func assertHeaderCount(ctx *Context, max int) bool {
+	if max <= 0 {
+		return true
+	}
+
+	count := 0
+	for _, values := range ctx.r.Header {
+		count += len(values)
+	}
+
+	if count > max {
+		ctx.RequestHeaderFieldsTooLarge(fmt.Sprintf("The request has %v headers, but at most %v are allowed.", count, max))
+		return false
+	}
+
+	return true
+}
 
+// loggedDuration returns the duration of the request as it should be logged.
+// If unit is set, the exact duration is given as a number of that unit, such as
+// 12.345ms.  Otherwise, the duration is given as returned by
+// MeasuredResponseWriter.Duration.
+func loggedDuration(mrw *MeasuredResponseWriter, unit time.Duration) string {
+	if unit <= 0 {
+		return mrw.Duration().String()
+	}
+
+	return fmt.Sprintf("%.3f%v", float64(mrw.RawDuration())/float64(unit), durationUnitSuffix(unit))
+}
+
+// emitServerTiming sets the Server-Timing header of the response to the
+// duration of the request so far.  If the headers of the response have already
+// been written, a note is logged instead when debugging is enabled.
+func emitServerTiming(ctx *Context, mrw *MeasuredResponseWriter, logger logging.Logger) {
+	if mrw.HasWrittenHeaders() {
+		if ctx.config.DebuggingEnabled {
+			resolveRequestLogger(ctx, logger).Printf("? Server-Timing was not emitted for %v as the headers were already written\n", ctx.r.URL.Path)
+		}
+
+		return
+	}
+
+	dur := float64(mrw.RawDuration()) / float64(time.Millisecond)
+	ctx.Header().Set("Server-Timing", fmt.Sprintf("app;dur=%.3f", dur))
+}
+
+// resolveRequestLogger resolves a logging.Logger from the container of the
+// request, falling back to the provided logger if none is registered.
+func resolveRequestLogger(ctx *Context, logger logging.Logger) logging.Logger {
+	var requestLogger logging.Logger
+
+	err := ctx.c.Resolve(&requestLogger)
+	if err != nil || requestLogger == nil {
+		return logger
+	}
+
+	return requestLogger
+}
+
+func (b *HandlerBuilder) buildHandlerForPath(path string, routes []Route) ContextHandlerFunc {
+	handlerByMethod := make(map[string]ContextHandlerFunc)
 	for _, route := range routes {
-		method := route.Method()
+		handlerByMethod[route.Method()] = buildHandlerForRoute(route, b.resolveNamedMiddleware(route))
+	}
 
-		handlerByMethod[method] = buildHandlerForRoute(route)
-		allowedMethods = append(allowedMethods, method)
+	if getHandler, ok := handlerByMethod[http.MethodGet]; ok && b.config.AutoHead {
+		if _, ok := handlerByMethod[http.MethodHead]; !ok {
+			handlerByMethod[http.MethodHead] = func(ctx *Context) {
+				ctx.w = &headResponseWriter{ResponseWriter: ctx.w}
+				getHandler(ctx)
+			}
+		}
 	}
 
+	allowedMethods := b.allowedMethods(routes)
+
 	return func(ctx *Context) {
 		if !ctx.AssertMethod(allowedMethods...) {
 			return
@@ -109,19 +639,315 @@ func buildHandlerForPath(path string, routes []Route) ContextHandlerFunc {
 	}
 }
 
-func buildHandlerForRoute(route Route) ContextHandlerFunc {
+// allowedMethods returns the sorted, distinct methods of the provided routes,
+// including HEAD if Config.AutoHead is set and there is a GET route.
+func (b *HandlerBuilder) allowedMethods(routes []Route) []string {
+	seen := make(map[string]bool)
+	allowedMethods := []string{}
+
+	for _, route := range routes {
+		method := route.Method()
+		if !seen[method] {
+			seen[method] = true
+			allowedMethods = append(allowedMethods, method)
+		}
+	}
+
+	if b.config.AutoHead && seen[http.MethodGet] && !seen[http.MethodHead] {
+		allowedMethods = append(allowedMethods, http.MethodHead)
+	}
+
+	sort.Strings(allowedMethods)
+	return allowedMethods
+}
+
+// headResponseWriter is used to serve HEAD requests with the handler for GET
+// requests.  Headers, including Content-Length, are written as normal, but the
+// body is discarded.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func buildHandlerForRoute(route Route, namedMiddleware []Middleware) ContextHandlerFunc {
 	return func(ctx *Context) {
-		for _, mw := range route.Middleware() {
-			shouldContinue := mw.Handle(ctx)
-			if !shouldContinue {
+		middleware := append([]Middleware{}, namedMiddleware...)
+		middleware = append(middleware, route.Middleware()...)
+
+		handleWithMiddleware(ctx, middleware, func(ctx *Context) {
+			if !assertRouteRequirements(ctx, route) {
 				return
 			}
+
+			route.Handle(ctx)
+		})
+	}
+}
+
+// buildHandlerWithMiddleware wraps the provided handler so that the provided
+// middleware are run around it.
+func buildHandlerWithMiddleware(middleware []Middleware, ctxHandler ContextHandlerFunc) ContextHandlerFunc {
+	if len(middleware) == 0 {
+		return ctxHandler
+	}
+
+	return func(ctx *Context) {
+		handleWithMiddleware(ctx, middleware, ctxHandler)
+	}
+}
+
+// handleWithMiddleware runs the provided middleware in order and, if none of
+// them halt the request, the provided handler.  The After and Finalize hooks
+// of the middleware that were run are called once the handler has finished.
+func handleWithMiddleware(ctx *Context, middleware []Middleware, ctxHandler ContextHandlerFunc) {
+	handled := []Middleware{}
+	defer func() {
+		for i := len(handled) - 1; i >= 0; i-- {
+			if afterMiddleware, ok := handled[i].(AfterMiddleware); ok {
+				afterMiddleware.After(ctx)
+			}
+		}
+	}()
+
+	if hasFinalizingMiddleware(middleware) {
+		ctx.BufferResponse()
+		defer func() {
+			for i := len(handled) - 1; i >= 0; i-- {
+				if finalizingMiddleware, ok := handled[i].(FinalizingMiddleware); ok {
+					finalizingMiddleware.Finalize(ctx, ctx.StatusCode())
+				}
+			}
+		}()
+	}
+
+	for i, mw := range middleware {
+		shouldContinue := handleMiddleware(ctx, i, mw)
+		if !shouldContinue {
+			if !ctx.ResponseWritten() {
+				ctx.InternalServerError(fmt.Errorf("the middleware %T halted the request without responding", mw))
+			}
+
+			return
 		}
 
-		route.Handle(ctx)
+		handled = append(handled, mw)
 	}
+
+	ctxHandler(ctx)
+}
+
+func hasFinalizingMiddleware(middleware []Middleware) bool {
+	for _, mw := range middleware {
+		if _, ok := mw.(FinalizingMiddleware); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handleMiddleware invokes the provided middleware, annotating any panic with
+// the type and index of the middleware so that it can be identified.
+func handleMiddleware(ctx *Context, index int, mw Middleware) bool {
+	defer func() {
+		if p := recover(); p != nil {
+			panic(&middlewarePanic{index: index, mw: mw, value: p})
+		}
+	}()
+
+	return mw.Handle(ctx)
 }
 
+type middlewarePanic struct {
+	index int
+	mw    Middleware
+	value interface{}
+}
+
+func (p *middlewarePanic) Error() string {
+	return fmt.Sprintf("the middleware %T at index %v panicked: %v", p.mw, p.index, p.value)
+}
+
+func (p *middlewarePanic) Unwrap() error {
+	err, _ := p.value.(error)
+	return err
+}
+
+func assertRouteRequirements(ctx *Context, route Route) bool {
+	if contentTypedRoute, ok := routeExtension(route).(interface{ Accepts() []string }); ok {
+		if !ctx.AssertContentType(contentTypedRoute.Accepts()...) {
+			return false
+		}
+	}
+
+	if lengthLimitedRoute, ok := routeExtension(route).(interface{ MaxContentLength() int64 }); ok && isMutatingMethod(ctx.r.Method) {
+		if !ctx.AssertContentLength(lengthLimitedRoute.MaxContentLength()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isMutatingMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	default:
+		return false
+	}
+}
+
+// purifyPath trims the provided path, ensures that it has a leading slash and
+// replaces backslashes with forward slashes.  Backslashes inside variables are
+// left untouched, so that regular expressions such as {path:\S+} survive.
 func purifyPath(path string) string {
-	return strings.TrimSpace(strings.ReplaceAll(path, "\\", "/"))
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "\\") {
+		path = "/" + path
+	}
+
+	var sb strings.Builder
+	depth := 0
+
+	for _, r := range path {
+		switch {
+		case r == '{':
+			depth++
+		case r == '}' && depth > 0:
+			depth--
+		case r == '\\' && depth == 0:
+			r = '/'
+		}
+
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
+
+func constrainPath(path string, constraints map[string]string) string {
+	for name, pattern := range constraints {
+		path = strings.ReplaceAll(path, fmt.Sprintf("{%v}", name), fmt.Sprintf("{%v:%v}", name, pattern))
+	}
+
+	return path
+}
+
+// sortTemplatesBySpecificity sorts the provided mux templates so that the most
+// specific templates come first.  Templates are compared segment by segment,
+// where a literal segment is more specific than a segment that contains a
+// variable, which is in turn more specific than a segment that is entirely a
+// variable.  Ties are broken by segment count and then lexically, so that the
+// resulting order is deterministic.
+func sortTemplatesBySpecificity(templates []string, separator string) {
+	sort.Slice(templates, func(i, j int) bool {
+		return isMoreSpecificTemplate(templates[i], templates[j], separator)
+	})
+}
+
+func isMoreSpecificTemplate(a string, b string, separator string) bool {
+	aSegments := splitTemplate(a, separator)
+	bSegments := splitTemplate(b, separator)
+
+	for i := 0; i < len(aSegments) && i < len(bSegments); i++ {
+		aRank := templateSegmentRank(aSegments[i])
+		bRank := templateSegmentRank(bSegments[i])
+
+		if aRank != bRank {
+			return aRank < bRank
+		}
+	}
+
+	if len(aSegments) != len(bSegments) {
+		return len(aSegments) > len(bSegments)
+	}
+
+	return a < b
+}
+
+// templatesOverlap returns true if the provided mux templates could both match
+// the same value.  Segments containing variables are assumed to be able to
+// match any value, and a final variable that can match the separator is
+// assumed to match any number of remaining segments, so the result is
+// conservative.
+func templatesOverlap(a string, b string, separator string) bool {
+	aSegments := splitTemplate(a, separator)
+	bSegments := splitTemplate(b, separator)
+
+	aCatchAll := isCatchAllSegment(aSegments[len(aSegments)-1], separator)
+	bCatchAll := isCatchAllSegment(bSegments[len(bSegments)-1], separator)
+
+	switch {
+	case aCatchAll && len(bSegments) >= len(aSegments):
+		aSegments = aSegments[:len(aSegments)-1]
+		bSegments = bSegments[:len(aSegments)]
+	case bCatchAll && len(aSegments) >= len(bSegments):
+		bSegments = bSegments[:len(bSegments)-1]
+		aSegments = aSegments[:len(bSegments)]
+	case len(aSegments) != len(bSegments):
+		return false
+	}
+
+	for i := range aSegments {
+		if templateSegmentRank(aSegments[i]) == 0 && templateSegmentRank(bSegments[i]) == 0 && aSegments[i] != bSegments[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// splitTemplate splits the provided mux template on the separator, ignoring any
+// separators inside variables, such as the slash in {path:[a-z/]+}.
+func splitTemplate(template string, separator string) []string {
+	segments := []string{}
+	depth := 0
+	start := 0
+
+	for i := 0; i < len(template); i++ {
+		switch {
+		case template[i] == '{':
+			depth++
+		case template[i] == '}' && depth > 0:
+			depth--
+		case depth == 0 && strings.HasPrefix(template[i:], separator):
+			segments = append(segments, template[start:i])
+			start = i + len(separator)
+			i += len(separator) - 1
+		}
+	}
+
+	return append(segments, template[start:])
+}
+
+// isCatchAllSegment returns true if the provided segment is entirely a variable
+// whose pattern can match the separator, such as {path:.*}.
+func isCatchAllSegment(segment string, separator string) bool {
+	if templateSegmentRank(segment) != 2 {
+		return false
+	}
+
+	colon := strings.Index(segment, ":")
+	if colon == -1 {
+		return false
+	}
+
+	re, err := regexp.Compile(segment[colon+1 : len(segment)-1])
+	return err == nil && re.MatchString(separator)
+}
+
+func templateSegmentRank(segment string) int {
+	if !strings.Contains(segment, "{") {
+		return 0
+	}
+
+	if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") && strings.Count(segment, "{") == 1 {
+		return 2
+	}
+
+	return 1
 }
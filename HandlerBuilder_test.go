@@ -1,11 +1,19 @@
 package web
 
 import (
+	"encoding/json"
 	"fmt"
+	"html/template"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/ljpx/di"
 	"github.com/ljpx/logging"
 	"github.com/ljpx/problem"
@@ -52,7 +60,7 @@ func TestHandlerBuilderNotFound(t *testing.T) {
 
 	test.That(t, problem.Type).IsEqualTo("https://testi.ng/http/not-found")
 	test.That(t, problem.Detail).IsEqualTo("The path '/hello' was not found.")
-	fixture.logger.AssertLogged(t, "• 404 0s 160.00 B /hello\n")
+	fixture.logger.AssertLogged(t, fmt.Sprintf("• 404 0s 160.00 B /hello %v\n", res.Header.Get("Correlation-ID")))
 }
 
 func TestHandlerBuilderSuccess(t *testing.T) {
@@ -98,47 +106,2173 @@ func TestHandlerBuilderPanic(t *testing.T) {
 
 	test.That(t, problem.Type).IsEqualTo("https://testi.ng/http/internal-server-error")
 	test.That(t, problem.Error).IsEqualTo("something to panic about")
+	fixture.logger.AssertLogged(t, "! /test/hello something to panic about\n")
+}
+
+func TestHandlerBuilderPanicWithErrorReachesOnPanic(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	panicErr := &testPanicError{}
+	var received error
+	fixture.x.config.OnPanic = func(ctx *Context, err error) {
+		received = err
+	}
+
+	fixture.x.Use(&testErrorPanickingRoute{err: panicErr})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/error-panicking", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	test.That(t, w.Result().StatusCode).IsEqualTo(http.StatusInternalServerError)
+	test.That(t, received).IsEqualTo(error(panicErr))
+}
+
+func TestHandlerBuilderPanicPropagatesWhenRecoveryDisabled(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.config.DisablePanicRecovery = true
+	onPanicCalled := false
+	fixture.x.config.OnPanic = func(ctx *Context, err error) {
+		onPanicCalled = true
+	}
+
+	panicErr := &testPanicError{}
+	fixture.x.Use(&testErrorPanickingRoute{err: panicErr})
+	handler := fixture.x.Build()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/error-panicking", nil)
+
+	// Act.
+	var recovered interface{}
+	func() {
+		defer func() {
+			recovered = recover()
+		}()
+
+		handler.ServeHTTP(w, r)
+	}()
+
+	// Assert.
+	test.That(t, recovered).IsEqualTo(interface{}(panicErr))
+	test.That(t, onPanicCalled).IsFalse()
+}
+
+func TestHandlerBuilderPanicWithHTTPErrorWhenRecoveryDisabled(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.config.DisablePanicRecovery = true
+	onPanicCalled := false
+	fixture.x.config.OnPanic = func(ctx *Context, err error) {
+		onPanicCalled = true
+	}
+
+	fixture.x.Use(&testErrorPanickingRoute{err: HTTPError{Status: http.StatusForbidden, Detail: "You may not do that."}})
+	handler := fixture.x.Build()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/error-panicking", nil)
+
+	// Act.
+	var recovered interface{}
+	func() {
+		defer func() {
+			recovered = recover()
+		}()
+
+		handler.ServeHTTP(w, r)
+	}()
+
+	// Assert.
+	test.That(t, recovered).IsNil()
+	test.That(t, onPanicCalled).IsFalse()
+
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusForbidden)
+
+	problem := &problem.Details{}
+	err := UnmarshalFromResponse(res, problem)
+	test.That(t, err).IsNil()
+	test.That(t, problem.Detail).IsEqualTo("You may not do that.")
+}
+
+func TestHandlerBuilderCatchAllRouteCapturesRemainder(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Use(&testCatchAllRoute{})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/files/a/b/c.txt", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	test.That(t, w.Result().StatusCode).IsEqualTo(http.StatusOK)
+	test.That(t, w.Body.String()).IsEqualTo(`{"message":"a/b/c.txt"}`)
+}
+
+func TestHandlerBuilderPanicWithHTTPError(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	onPanicCalled := false
+	fixture.x.config.OnPanic = func(ctx *Context, err error) {
+		onPanicCalled = true
+	}
+
+	fixture.x.Use(&testErrorPanickingRoute{err: HTTPError{Status: http.StatusForbidden, Detail: "You may not do that."}})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/error-panicking", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusForbidden)
+	test.That(t, onPanicCalled).IsFalse()
+
+	problem := &problem.Details{}
+	err := UnmarshalFromResponse(res, problem)
+	test.That(t, err).IsNil()
+
+	test.That(t, problem.Type).IsEqualTo("https://testi.ng/http/forbidden")
+	test.That(t, problem.Title).IsEqualTo("Forbidden")
+	test.That(t, problem.Detail).IsEqualTo("You may not do that.")
+}
+
+func TestHandlerBuilderErrRouteReturnsHTTPError(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.UseErrRoute(&testErrRoute{err: HTTPError{Status: http.StatusForbidden}})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/err-route", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusForbidden)
+
+	problem := &problem.Details{}
+	err := UnmarshalFromResponse(res, problem)
+	test.That(t, err).IsNil()
+	test.That(t, problem.Type).IsEqualTo("https://testi.ng/http/forbidden")
+}
+
+func TestHandlerBuilderErrRouteReturnsGenericError(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.UseErrRoute(&testErrRoute{err: fmt.Errorf("ahhh")})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/err-route", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusInternalServerError)
+
+	problem := &problem.Details{}
+	err := UnmarshalFromResponse(res, problem)
+	test.That(t, err).IsNil()
+	test.That(t, problem.Error).IsEqualTo("ahhh")
+}
+
+func TestHandlerBuilderErrRouteReturnsNilWithoutResponding(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.UseErrRoute(&testErrRoute{})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/err-route", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	test.That(t, w.Result().StatusCode).IsEqualTo(http.StatusNoContent)
+}
+
+func TestHandlerBuilderErrRouteReturnsNilAfterResponding(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.UseErrRoute(&testErrRoute{respond: true})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/err-route", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	test.That(t, w.Result().StatusCode).IsEqualTo(http.StatusOK)
+	test.That(t, w.Body.String()).IsEqualTo(`{"message":"Hello, World!"}`)
+}
+
+func TestHandlerBuilderErrRouteReturnsErrorAfterResponding(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.UseErrRoute(&testErrRoute{err: fmt.Errorf("ahhh"), respond: true})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/err-route", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	test.That(t, w.Result().StatusCode).IsEqualTo(http.StatusOK)
+	test.That(t, w.Body.String()).IsEqualTo(`{"message":"Hello, World!"}`)
+	fixture.logger.AssertLogged(t, "! /err-route ahhh\n")
+}
+
+func TestHandlerBuilderErrRouteHonoursConstraintsAndName(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.UseErrRoute(&testExtendedErrRoute{})
+	handler := fixture.x.Build()
+
+	// Act.
+	url, err := fixture.x.URLFor("putErrWidget", "id", "1234")
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPut, "/err-widgets/abc", strings.NewReader(`{}`))
+	r.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	test.That(t, err).IsNil()
+	test.That(t, url).IsEqualTo("/err-widgets/1234")
+	test.That(t, w.Result().StatusCode).IsEqualTo(http.StatusNotFound)
+}
+
+func TestHandlerBuilderErrRouteHonoursAccepts(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	route := &testExtendedErrRoute{}
+	fixture.x.UseErrRoute(route)
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPut, "/err-widgets/1234", strings.NewReader("Hello, World!"))
+	r.Header.Set("Content-Type", "text/plain")
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	test.That(t, w.Result().StatusCode).IsEqualTo(http.StatusUnsupportedMediaType)
+	test.That(t, route.calls).IsEqualTo(0)
+}
+
+func TestHandlerBuilderErrRouteIsDocumented(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.UseErrRoute(&testExtendedErrRoute{})
+
+	// Act.
+	rawSpec, err := fixture.x.OpenAPISpec()
+
+	// Assert.
+	test.That(t, err).IsNil()
+
+	spec := map[string]interface{}{}
+	err = json.Unmarshal(rawSpec, &spec)
+	test.That(t, err).IsNil()
+
+	paths := spec["paths"].(map[string]interface{})
+	operation := paths["/err-widgets/{id}"].(map[string]interface{})["put"].(map[string]interface{})
+	test.That(t, operation["operationId"]).IsEqualTo("putErrWidget")
+	test.That(t, operation["summary"]).IsEqualTo("Replaces a widget, or fails.")
+}
+
+func TestHandlerBuilderLogsJSONArrayFailureToBuilderLogger(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Use(&testFailingStreamRoute{})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/stream", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	test.That(t, w.Body.String()).IsEqualTo(`[{"message":"one"}`)
+	fixture.logger.AssertLogged(t, "! /stream streaming the JSON array failed after 1 elements: json: unsupported type: func()\n")
+}
+
+func TestHandlerBuilderRejectsExcessiveHeaders(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.config.MaxHeaderCount = 10
+	handler := fixture.x.Build()
+
+	r := httptest.NewRequest(http.MethodGet, "/test/hello", nil)
+	for i := 0; i < 11; i++ {
+		r.Header.Add(fmt.Sprintf("X-Header-%v", i), "value")
+	}
+
+	// Act.
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusRequestHeaderFieldsTooLarge)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/request-header-fields-too-large","title":"Request Header Fields Too Large","detail":"The request has 11 headers, but at most 10 are allowed."}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
+
+func TestHandlerBuilderAllowsHeadersWithinLimit(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.config.MaxHeaderCount = 10
+	handler := fixture.x.Build()
+
+	r := httptest.NewRequest(http.MethodGet, "/test/hello", nil)
+	for i := 0; i < 10; i++ {
+		r.Header.Add(fmt.Sprintf("X-Header-%v", i), "value")
+	}
+
+	// Act.
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	test.That(t, w.Result().StatusCode).IsEqualTo(http.StatusOK)
+}
+
+func TestHandlerBuilderDefaultHeaders(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.config.DefaultHeaders = http.Header{
+		"X-Service-Name":    []string{"web"},
+		"X-Service-Version": []string{"1.2.3"},
+	}
+
+	handler := fixture.x.Build()
+
+	for _, path := range []string{"/test/hello", "/nowhere"} {
+		// Act.
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		handler.ServeHTTP(w, r)
+
+		// Assert.
+		res := w.Result()
+		test.That(t, res.Header.Get("X-Service-Name")).IsEqualTo("web")
+		test.That(t, res.Header.Get("X-Service-Version")).IsEqualTo("1.2.3")
+	}
+}
+
+func TestHandlerBuilderDefaultHeadersCanBeOverridden(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.config.DefaultHeaders = http.Header{
+		"Cache-Control": []string{"no-store"},
+	}
+
+	fixture.x.Use(&testDefaultHeaderOverridingRoute{})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/cacheable", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	test.That(t, w.Result().Header.Get("Cache-Control")).IsEqualTo("max-age=60")
+	test.That(t, fixture.x.config.DefaultHeaders.Get("Cache-Control")).IsEqualTo("no-store")
+}
+
+func TestHandlerBuilderGlobalMiddlewareRunsForUnknownPath(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.UseGlobalMiddleware(&testAuthMiddleware{})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/nowhere", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	test.That(t, w.Result().StatusCode).IsEqualTo(http.StatusUnauthorized)
+}
+
+func TestHandlerBuilderGlobalMiddlewareAllowsUnknownPath(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.UseGlobalMiddleware(&testAuthMiddleware{})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/nowhere", nil)
+	r.Header.Set("Authorization", "Bearer token")
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	test.That(t, w.Result().StatusCode).IsEqualTo(http.StatusNotFound)
+}
+
+func TestHandlerBuilderGlobalMiddlewareRunsBeforeRouteMiddleware(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	calls := []string{}
+	fixture.x.UseGlobalMiddleware(&testRecordingMiddleware{name: "global", calls: &calls})
+	fixture.x.UseNamedMiddleware("named", &testRecordingMiddleware{name: "named", calls: &calls})
+	fixture.x.Use(&testNamedMiddlewareRoute{path: "/named", namedMiddleware: []string{"named"}, calls: &calls})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/named", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	test.That(t, strings.Join(calls, ",")).IsEqualTo("global,named,own,/named")
+}
+
+func TestHandlerBuilderHTTPMiddlewareWrapsEveryResponse(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	calls := []string{}
+	fixture.x.UseHTTPMiddleware(testHeaderSettingHTTPMiddleware("outer", &calls))
+	fixture.x.UseHTTPMiddleware(testHeaderSettingHTTPMiddleware("inner", &calls))
+	handler := fixture.x.Build()
+
+	for _, path := range []string{"/test/hello", "/nowhere"} {
+		// Act.
+		calls = []string{}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		handler.ServeHTTP(w, r)
+
+		// Assert.
+		test.That(t, w.Result().Header.Get("X-Wrapped-By")).IsEqualTo("inner")
+		test.That(t, strings.Join(calls, ",")).IsEqualTo("outer,inner")
+	}
+}
+
+func TestHandlerBuilderHTMLErrorTemplateForBrowsers(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.config.HTMLErrorTemplate = template.Must(template.New("error").Parse(`<h1>{{.Title}}</h1><p>{{.Detail}}</p>`))
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/nowhere", nil)
+	r.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusNotFound)
+	test.That(t, res.Header.Get("Content-Type")).IsEqualTo("text/html; charset=utf-8")
+	test.That(t, w.Body.String()).IsEqualTo("<h1>Not Found</h1><p>The path &#39;/nowhere&#39; was not found.</p>")
+}
+
+func TestHandlerBuilderHTMLErrorTemplateIgnoredForJSONClients(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.config.HTMLErrorTemplate = template.Must(template.New("error").Parse(`<h1>{{.Title}}</h1>`))
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/nowhere", nil)
+	r.Header.Set("Accept", "application/json")
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusNotFound)
+	test.That(t, res.Header.Get("Content-Type")).IsEqualTo("application/json")
+
+	problem := &problem.Details{}
+	err := UnmarshalFromResponse(res, problem)
+	test.That(t, err).IsNil()
+	test.That(t, problem.Title).IsEqualTo("Not Found")
+}
+
+func TestHandlerBuilderResponseTransformerWrapsBody(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.UseGlobalMiddleware(&testEnvelopeMiddleware{})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/test/hello", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+
+	body := w.Body.String()
+	test.That(t, body).IsEqualTo(`{"status":200,"data":{"message":"hello "}}`)
+	test.That(t, res.Header.Get("Content-Length")).IsEqualTo(strconv.Itoa(len(body)))
+}
+
+func TestHandlerBuilderLogsDurationInConfiguredUnit(t *testing.T) {
+	// Arrange.
+	logger := &testRecordingLogger{}
+	x := NewHandlerBuilder(di.NewContainer(), logger, &Config{
+		ProblemDetailsTypePrefix: "https://testi.ng",
+		DurationUnit:             time.Millisecond,
+	})
+
+	x.Use(&testRoute{})
+	handler := x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/test/hello", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	test.That(t, len(logger.messages)).IsEqualTo(1)
+	test.That(t, regexp.MustCompile(`^• 200 \d+\.\d{3}ms \S+ B /test/hello [0-9a-f]+\n$`).MatchString(logger.messages[0])).IsTrue()
+}
+
+func TestHandlerBuilderAllowedMethods(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Use(&testMethodRoute{method: http.MethodPut})
+	fixture.x.Use(&testMethodRoute{method: http.MethodGet})
+
+	// Act.
+	before := fixture.x.AllowedMethods("/methods")
+	fixture.x.Build()
+	after := fixture.x.AllowedMethods(" /methods ")
+	missing := fixture.x.AllowedMethods("/missing")
+
+	// Assert.
+	test.That(t, strings.Join(before, ",")).IsEqualTo("GET,PUT")
+	test.That(t, strings.Join(after, ",")).IsEqualTo("GET,PUT")
+	test.That(t, len(missing)).IsEqualTo(0)
+}
+
+func TestHandlerBuilderAllowedMethodsIncludesHostRoutes(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Use(&testMethodRoute{method: http.MethodPut})
+	fixture.x.Host("api.example.com").Use(&testMethodRoute{method: http.MethodGet})
+
+	// Act.
+	all := fixture.x.AllowedMethods("/methods")
+	host := fixture.x.Host("api.example.com").AllowedMethods("/methods")
+
+	// Assert.
+	test.That(t, strings.Join(all, ",")).IsEqualTo("GET,PUT")
+	test.That(t, strings.Join(host, ",")).IsEqualTo("GET")
+}
+
+func TestHandlerBuilderAllowedMethodsForConstrainedRoute(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.config.AutoHead = true
+	fixture.x.Use(&testConstrainedRoute{})
+
+	// Act.
+	methods := fixture.x.AllowedMethods("/users/{id}")
+
+	// Assert.
+	test.That(t, strings.Join(methods, ",")).IsEqualTo("GET,HEAD")
+}
+
+func TestHandlerBuilderOpenAPISpec(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Use(&testDocumentedRoute{})
+
+	// Act.
+	rawSpec, err := fixture.x.OpenAPISpec()
+
+	// Assert.
+	test.That(t, err).IsNil()
+
+	spec := map[string]interface{}{}
+	err = json.Unmarshal(rawSpec, &spec)
+	test.That(t, err).IsNil()
+	test.That(t, spec["openapi"]).IsEqualTo("3.0.3")
+
+	paths := spec["paths"].(map[string]interface{})
+	operation := paths["/widgets/{id}"].(map[string]interface{})["put"].(map[string]interface{})
+	test.That(t, operation["summary"]).IsEqualTo("Replaces a widget.")
+
+	rawOperation, err := json.Marshal(operation)
+	test.That(t, err).IsNil()
+
+	expectedJSON := `{"parameters":[{"in":"path","name":"id","required":true,"schema":{"type":"string"}}],` +
+		`"requestBody":{"content":{"application/json":{"schema":{"properties":{"message":{"type":"string"}},"type":"object"}}},"required":true},` +
+		`"responses":{"200":{"content":{"application/json":{"schema":{"properties":{"message":{"type":"string"}},"type":"object"}}},"description":"OK"},` +
+		`"default":{"content":{"application/problem+json":{"schema":{"type":"object"}}},"description":"A problem describing why the request failed."}},` +
+		`"summary":"Replaces a widget."}`
+	test.That(t, string(rawOperation)).IsEqualTo(expectedJSON)
+
+	_, ok := paths["/test/{val1}"].(map[string]interface{})["get"]
+	test.That(t, ok).IsTrue()
+}
+
+func TestHandlerBuilderMiddlewarePanic(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Use(&testPanickingMiddlewareRoute{})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/panicking", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusInternalServerError)
+	fixture.logger.AssertLogged(t, "! /panicking the middleware *web.testPanickingMiddleware at index 1 panicked: something to panic about\n")
+}
+
+func TestHandlerBuilderConstrainedRouteMatches(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Use(&testConstrainedRoute{})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/users/1234", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+
+	resModel := &testResponseModel{}
+	err := UnmarshalFromResponse(res, resModel)
+	test.That(t, err).IsNil()
+	test.That(t, resModel.Message).IsEqualTo("1234")
+}
+
+func TestHandlerBuilderConstrainedRouteRejectsNonMatching(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Use(&testConstrainedRoute{})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/users/abc", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusNotFound)
+}
+
+func TestHandlerBuilderTrailingSlash(t *testing.T) {
+	testCases := []struct {
+		strictSlash           bool
+		redirectTrailingSlash bool
+		method                string
+		path                  string
+		expectedStatusCode    int
+		expectedLocation      string
+	}{
+		{path: "/test/hello", expectedStatusCode: http.StatusOK},
+		{path: "/test/hello/", expectedStatusCode: http.StatusNotFound},
+		{strictSlash: true, path: "/test/hello", expectedStatusCode: http.StatusOK},
+		{strictSlash: true, path: "/test/hello/", expectedStatusCode: http.StatusMovedPermanently, expectedLocation: "/test/hello"},
+		{redirectTrailingSlash: true, path: "/test/hello", expectedStatusCode: http.StatusOK},
+		{redirectTrailingSlash: true, path: "/test/hello/?val2=world", expectedStatusCode: http.StatusMovedPermanently, expectedLocation: "/test/hello?val2=world"},
+		{redirectTrailingSlash: true, method: http.MethodPost, path: "/test/hello/", expectedStatusCode: http.StatusPermanentRedirect, expectedLocation: "/test/hello"},
+		{redirectTrailingSlash: true, path: "/nothing/", expectedStatusCode: http.StatusNotFound},
+		{redirectTrailingSlash: true, path: "/test/hello/extra/", expectedStatusCode: http.StatusNotFound},
+	}
+
+	for _, testCase := range testCases {
+		// Arrange.
+		fixture := SetupHandlerBuilderFixture()
+		fixture.x.config.StrictSlash = testCase.strictSlash
+		fixture.x.config.RedirectTrailingSlash = testCase.redirectTrailingSlash
+		handler := fixture.x.Build()
+
+		method := testCase.method
+		if method == "" {
+			method = http.MethodGet
+		}
+
+		// Act.
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, testCase.path, nil)
+		handler.ServeHTTP(w, r)
+
+		// Assert.
+		res := w.Result()
+		test.That(t, res.StatusCode).IsEqualTo(testCase.expectedStatusCode)
+		test.That(t, res.Header.Get("Location")).IsEqualTo(testCase.expectedLocation)
+	}
+}
+
+func TestHandlerBuilderOpenAPISpecIncludesHostRoutes(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Use(&testDocumentedRoute{})
+	fixture.x.Host("api.example.com").Use(&testHostRoute{message: "api"})
+
+	// Act.
+	rawSpec, err := fixture.x.OpenAPISpec()
+
+	// Assert.
+	test.That(t, err).IsNil()
+
+	spec := map[string]interface{}{}
+	err = json.Unmarshal(rawSpec, &spec)
+	test.That(t, err).IsNil()
+
+	paths := spec["paths"].(map[string]interface{})
+	_, ok := paths["/whoami"].(map[string]interface{})["get"]
+	test.That(t, ok).IsTrue()
+}
+
+func TestHandlerBuilderOpenAPISpecHostCollision(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Host("api.example.com").Use(&testHostRoute{message: "api"})
+	fixture.x.Host("admin.example.com").Use(&testHostRoute{message: "admin"})
+
+	// Act.
+	rawSpec, err := fixture.x.OpenAPISpec()
+
+	// Assert.
+	test.That(t, len(rawSpec)).IsEqualTo(0)
+	test.That(t, err).IsNotNil()
+	test.That(t, err.Error()).IsEqualTo("the OpenAPI operation GET /whoami is registered for both host admin.example.com and host api.example.com")
+}
+
+func TestHandlerBuilderHost(t *testing.T) {
+	testCases := []struct {
+		host            string
+		expectedMessage string
+	}{
+		{host: "api.example.com", expectedMessage: "api"},
+		{host: "acme.tenants.example.com", expectedMessage: "tenant acme"},
+	}
+
+	for _, testCase := range testCases {
+		// Arrange.
+		fixture := SetupHandlerBuilderFixture()
+		fixture.x.Host("api.example.com").Use(&testHostRoute{message: "api"})
+		fixture.x.Host("{tenant}.tenants.example.com").Use(&testHostRoute{message: "tenant"})
+		handler := fixture.x.Build()
+
+		// Act.
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+		r.Host = testCase.host
+		handler.ServeHTTP(w, r)
+
+		// Assert.
+		res := w.Result()
+		test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+
+		resModel := &testResponseModel{}
+		err := UnmarshalFromResponse(res, resModel)
+		test.That(t, err).IsNil()
+		test.That(t, resModel.Message).IsEqualTo(testCase.expectedMessage)
+	}
+}
+
+func TestHandlerBuilderHostUnknown(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Host("api.example.com").Use(&testHostRoute{message: "api"})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	r.Host = "other.example.com"
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusNotFound)
+}
+
+func TestHandlerBuilderBasePath(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.config.BasePath = "/api/"
+	fixture.x.Use(&testConstrainedRoute{})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/test/hello?val2=world", nil)
+	r.Header.Set("X-Extra", "!")
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+
+	resModel := &testResponseModel{}
+	err := UnmarshalFromResponse(res, resModel)
+	test.That(t, err).IsNil()
+	test.That(t, resModel.Message).IsEqualTo("hello world!")
+
+	url, err := fixture.x.URLFor("getUser", "id", "1234")
+	test.That(t, err).IsNil()
+	test.That(t, url).IsEqualTo("/api/users/1234")
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/test/hello", nil)
+	handler.ServeHTTP(w, r)
+	test.That(t, w.Result().StatusCode).IsEqualTo(http.StatusNotFound)
+	test.That(t, w.Result().Header.Get("Content-Type")).IsEqualTo("application/json")
+}
+
+func TestHandlerBuilderBasePathRootRoute(t *testing.T) {
+	testCases := []struct {
+		strictSlash        bool
+		path               string
+		expectedStatusCode int
+		expectedLocation   string
+	}{
+		{path: "/api", expectedStatusCode: http.StatusOK},
+		{path: "/api/", expectedStatusCode: http.StatusOK},
+		{path: "/apiary", expectedStatusCode: http.StatusNotFound},
+		{strictSlash: true, path: "/api", expectedStatusCode: http.StatusMovedPermanently, expectedLocation: "/api/"},
+		{strictSlash: true, path: "/api/", expectedStatusCode: http.StatusOK},
+	}
+
+	for _, testCase := range testCases {
+		// Arrange.
+		fixture := SetupHandlerBuilderFixture()
+		fixture.x.config.BasePath = "/api"
+		fixture.x.config.StrictSlash = testCase.strictSlash
+		fixture.x.Use(&testOverlappingRoute{path: "/"})
+		handler := fixture.x.Build()
+
+		// Act.
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, testCase.path, nil)
+		handler.ServeHTTP(w, r)
+
+		// Assert.
+		res := w.Result()
+		test.That(t, res.StatusCode).IsEqualTo(testCase.expectedStatusCode)
+		test.That(t, res.Header.Get("Location")).IsEqualTo(testCase.expectedLocation)
+	}
+}
+
+func TestHandlerBuilderBuildInto(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	router := mux.NewRouter()
+	router.HandleFunc("/custom", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	// Act.
+	handler := fixture.x.BuildInto(router)
+
+	// Assert.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/custom", nil)
+	handler.ServeHTTP(w, r)
+	test.That(t, w.Result().StatusCode).IsEqualTo(http.StatusTeapot)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/test/hello?val2=world", nil)
+	handler.ServeHTTP(w, r)
+
+	resModel := &testResponseModel{}
+	err := UnmarshalFromResponse(w.Result(), resModel)
+	test.That(t, err).IsNil()
+	test.That(t, resModel.Message).IsEqualTo("hello world")
+}
+
+func TestHandlerBuilderMethodOverride(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.config.AllowMethodOverride = true
+	route := &testDeleteRoute{}
+	fixture.x.Use(route)
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/things/1", nil)
+	r.Header.Set("X-HTTP-Method-Override", "delete")
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	test.That(t, w.Result().StatusCode).IsEqualTo(http.StatusNoContent)
+	test.That(t, route.calls).IsEqualTo(1)
+	test.That(t, route.method).IsEqualTo(http.MethodDelete)
+	test.That(t, route.path).IsEqualTo("/things/1")
+	test.That(t, r.Method).IsEqualTo(http.MethodPost)
+}
+
+func TestHandlerBuilderMethodOverrideDisabled(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	route := &testDeleteRoute{}
+	fixture.x.Use(route)
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/things/1", nil)
+	r.Header.Set("X-HTTP-Method-Override", "DELETE")
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	test.That(t, w.Result().StatusCode).IsEqualTo(http.StatusMethodNotAllowed)
+	test.That(t, route.calls).IsEqualTo(0)
+}
+
+func TestHandlerBuilderMethodNotAllowedSortsMethods(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Use(&testMethodRoute{method: http.MethodPut})
+	fixture.x.Use(&testMethodRoute{method: http.MethodDelete})
+	fixture.x.Use(&testMethodRoute{method: http.MethodPatch})
+	fixture.x.Use(&testMethodRoute{method: http.MethodDelete})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/methods", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusMethodNotAllowed)
+	test.That(t, res.Header.Get("Allow")).IsEqualTo("DELETE, PATCH, PUT")
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+	test.That(t, strings.Contains(string(rawJSON), `"allowedMethods":["DELETE","PATCH","PUT"]`)).IsTrue()
+}
+
+func TestHandlerBuilderURLForSuccess(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Use(&testConstrainedRoute{})
+	fixture.x.Build()
+
+	// Act.
+	url, err := fixture.x.URLFor("getUser", "id", "1234")
+
+	// Assert.
+	test.That(t, err).IsNil()
+	test.That(t, url).IsEqualTo("/users/1234")
+}
+
+func TestHandlerBuilderURLForConstraintViolated(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Use(&testConstrainedRoute{})
+
+	// Act.
+	_, err := fixture.x.URLFor("getUser", "id", "abc")
+
+	// Assert.
+	test.That(t, err).IsNotNil()
+}
+
+func TestHandlerBuilderURLForUnknownName(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+
+	// Act.
+	_, err := fixture.x.URLFor("getUser", "id", "1234")
+
+	// Assert.
+	test.That(t, err).IsNotNil()
+	test.That(t, err.Error()).IsEqualTo("no route with the name 'getUser' has been registered")
+}
+
+func TestHandlerBuilderBufferedResponseMutatedByMiddleware(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Use(&testBufferedRoute{})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/buffered", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+	test.That(t, res.Header.Get("Content-Length")).IsEqualTo("22")
+
+	resModel := &testResponseModel{}
+	err := UnmarshalFromResponse(res, resModel)
+	test.That(t, err).IsNil()
+	test.That(t, resModel.Message).IsEqualTo("Goodbye!")
+	fixture.logger.AssertLogged(t, fmt.Sprintf("• 200 0s 22.00 B /buffered %v\n", res.Header.Get("Correlation-ID")))
+}
+
+func TestHandlerBuilderFinalizingMiddlewareSetsHeaderAfterHandler(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Use(&testFinalizedRoute{})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/finalized", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusCreated)
+	test.That(t, res.Header.Get("X-Finalized-Status")).IsEqualTo("201")
+	test.That(t, w.Body.String()).IsEqualTo(`{"message":"Hello, World!"}`)
+}
+
+func TestHandlerBuilderEmitsServerTimingForBufferedResponse(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.config.EmitServerTiming = true
+	fixture.x.Use(&testBufferedRoute{})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/buffered", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+	test.That(t, strings.HasPrefix(res.Header.Get("Server-Timing"), "app;dur=")).IsTrue()
+}
+
+func TestHandlerBuilderSkipsServerTimingForWrittenResponse(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.config.EmitServerTiming = true
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/test/hello", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+	test.That(t, res.Header.Get("Server-Timing")).IsEqualTo("")
+	fixture.logger.AssertLogged(t, "? Server-Timing was not emitted for /test/hello as the headers were already written\n")
+}
+
+func TestHandlerBuilderAutoHeadPreservesContentLength(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.config.AutoHead = true
+	handler := fixture.x.Build()
+
+	getW := httptest.NewRecorder()
+	handler.ServeHTTP(getW, httptest.NewRequest(http.MethodGet, "/test/hello", nil))
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodHead, "/test/hello", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+	test.That(t, res.Header.Get("Content-Length")).IsEqualTo(strconv.Itoa(getW.Body.Len()))
+	test.That(t, w.Body.Len()).IsEqualTo(0)
+}
+
+func TestHandlerBuilderAutoHeadDisabled(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodHead, "/test/hello", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusMethodNotAllowed)
+	test.That(t, res.Header.Get("Allow")).IsEqualTo("GET")
+}
+
+func TestHandlerBuilderNamedMiddlewareOrder(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	calls := []string{}
+
+	fixture.x.UseNamedMiddleware("auth", &testRecordingMiddleware{name: "auth", calls: &calls})
+	fixture.x.UseNamedMiddleware("tenant", &testRecordingMiddleware{name: "tenant", calls: &calls})
+	fixture.x.Use(&testNamedMiddlewareRoute{path: "/a", namedMiddleware: []string{"tenant", "auth"}, calls: &calls})
+	fixture.x.Use(&testNamedMiddlewareRoute{path: "/b", namedMiddleware: []string{"tenant"}, calls: &calls})
+	handler := fixture.x.Build()
+
+	// Act.
+	for _, path := range []string{"/a", "/b"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		handler.ServeHTTP(w, r)
+	}
+
+	// Assert.
+	test.That(t, calls).HasEquivalentSequenceTo([]string{"auth", "tenant", "own", "/a", "tenant", "own", "/b"})
+}
+
+func TestHandlerBuilderMiddlewareHaltsWithoutResponding(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Use(&testMisbehavingRoute{})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/misbehaving", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusInternalServerError)
+
+	problem := &problem.Details{}
+	err := UnmarshalFromResponse(res, problem)
+	test.That(t, err).IsNil()
+
+	test.That(t, problem.Type).IsEqualTo("https://testi.ng/http/internal-server-error")
+	test.That(t, problem.Error).IsEqualTo("the middleware *web.testHaltingMiddleware halted the request without responding")
+}
+
+func TestHandlerBuilderMostSpecificRouteMatches(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		// Arrange.
+		fixture := SetupHandlerBuilderFixture()
+		fixture.x.Use(&testOverlappingRoute{path: "/users/{id}"})
+		fixture.x.Use(&testOverlappingRoute{path: "/users/me"})
+		fixture.x.Use(&testOverlappingRoute{path: "/{collection}/me"})
+		handler := fixture.x.Build()
+
+		// Act.
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+		handler.ServeHTTP(w, r)
+
+		// Assert.
+		resModel := &testResponseModel{}
+		err := UnmarshalFromResponse(w.Result(), resModel)
+		test.That(t, err).IsNil()
+		test.That(t, resModel.Message).IsEqualTo("/users/me")
+	}
+}
+
+func TestHandlerBuilderConflicts(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Use(&testOverlappingRoute{path: "/users/{id}"})
+	fixture.x.Use(&testOverlappingRoute{path: "/users/me"})
+	fixture.x.Use(&testOverlappingRoute{path: "/users/me/avatar"})
+	fixture.x.Host("api.example.com").Use(&testOverlappingRoute{path: "/a/{b}"})
+	fixture.x.Host("api.example.com").Use(&testOverlappingRoute{path: "/{a}/b"})
+	fixture.x.Use(&testOverlappingRoute{path: "/files/{path:.*}"})
+	fixture.x.Use(&testOverlappingRoute{path: "/files/special/{id}"})
+	fixture.x.Use(&testOverlappingRoute{path: "/images/{path:[a-z/]+}"})
+	fixture.x.Use(&testOverlappingRoute{path: "/images/logo.png"})
+	fixture.x.Use(&testOverlappingRoute{path: "/docs/{name:[^/]+}"})
+	fixture.x.Use(&testOverlappingRoute{path: "/docs/a/b"})
+
+	// Act.
+	conflicts := fixture.x.Conflicts()
+
+	// Assert.
+	test.That(t, len(conflicts)).IsEqualTo(4)
+	test.That(t, conflicts[0]).IsEqualTo(Conflict{Preferred: "/files/special/{id}", Shadowed: "/files/{path:.*}"})
+	test.That(t, conflicts[1]).IsEqualTo(Conflict{Preferred: "/images/logo.png", Shadowed: "/images/{path:[a-z/]+}"})
+	test.That(t, conflicts[2]).IsEqualTo(Conflict{Preferred: "/users/me", Shadowed: "/users/{id}"})
+	test.That(t, conflicts[3]).IsEqualTo(Conflict{Host: "api.example.com", Preferred: "/a/{b}", Shadowed: "/{a}/b"})
+	test.That(t, conflicts[3].String()).IsEqualTo("the path '/a/{b}' shadows the path '/{a}/b' on the host 'api.example.com'")
+}
+
+func TestHandlerBuilderRouteConflictHandler(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Use(&testOverlappingRoute{path: "/test/me"})
+
+	conflicts := []Conflict{}
+	fixture.x.config.RouteConflictHandler = func(conflict Conflict) {
+		conflicts = append(conflicts, conflict)
+	}
+
+	// Act.
+	fixture.x.Build()
+
+	// Assert.
+	test.That(t, len(conflicts)).IsEqualTo(1)
+	test.That(t, conflicts[0].String()).IsEqualTo("the path '/test/me' shadows the path '/test/{val1}'")
+}
+
+func TestHandlerBuilderLogsCorrelationIDWhenHeaderSuppressed(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.config.SuppressCorrelationIDHeader = true
+	route := &testCorrelationIDRoute{}
+	fixture.x.Use(route)
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/correlated", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	test.That(t, w.Result().Header.Get("Correlation-ID")).IsEqualTo("")
+	test.That(t, route.correlationID).IsNotEqualTo("")
+	fixture.logger.AssertLogged(t, fmt.Sprintf("• 204 0s 0.00 B /correlated %v\n", route.correlationID))
+}
+
+func TestHandlerBuilderRequestScopedLogger(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	requestLogger := logging.NewDummyLogger()
+	fixture.x.Use(&testRequestLoggerRoute{logger: requestLogger})
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/logged", nil)
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	logmsg := fmt.Sprintf("• 204 0s 0.00 B /logged %v\n", w.Result().Header.Get("Correlation-ID"))
+	requestLogger.AssertLogged(t, logmsg)
+
+	recorder := test.NewRecorder()
+	fixture.logger.AssertLogged(recorder, logmsg)
+	test.That(t, recorder.DidFail).IsTrue()
+}
+
+func TestHandlerBuilderUseAll(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	routes := []Route{
+		&testOverlappingRoute{path: "/a"},
+		&testOverlappingRoute{path: "/b"},
+		&testOverlappingRoute{path: "/c"},
+	}
+
+	// Act.
+	fixture.x.UseAll(routes...)
+	handler := fixture.x.Build()
+
+	// Assert.
+	for _, path := range []string{"/a", "/b", "/c"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		handler.ServeHTTP(w, r)
+
+		resModel := &testResponseModel{}
+		err := UnmarshalFromResponse(w.Result(), resModel)
+		test.That(t, err).IsNil()
+		test.That(t, resModel.Message).IsEqualTo(path)
+	}
+}
+
+func TestHandlerBuilderContentTypedRouteRejectsMismatch(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	route := &testContentTypedRoute{}
+	fixture.x.Use(route)
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/typed", strings.NewReader("Hello, World!"))
+	r.Header.Set("Content-Type", "text/plain")
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusUnsupportedMediaType)
+	test.That(t, route.calls).IsEqualTo(0)
+
+	problem := &problem.Details{}
+	err := UnmarshalFromResponse(res, problem)
+	test.That(t, err).IsNil()
+	test.That(t, problem.Type).IsEqualTo("https://testi.ng/http/unsupported-media-type")
+}
+
+func TestHandlerBuilderContentTypedRouteAcceptsMatch(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	route := &testContentTypedRoute{}
+	fixture.x.Use(route)
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/typed", strings.NewReader(`{"message":"Hello, World!"}`))
+	r.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	test.That(t, w.Result().StatusCode).IsEqualTo(http.StatusNoContent)
+	test.That(t, route.calls).IsEqualTo(1)
+}
+
+func TestHandlerBuilderLengthLimitedRouteRejectsOversizeBody(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	route := &testLengthLimitedRoute{}
+	fixture.x.Use(route)
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/limited", strings.NewReader("Hello, World!"))
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusRequestEntityTooLarge)
+	test.That(t, route.calls).IsEqualTo(0)
+}
+
+func TestHandlerBuilderLengthLimitedRouteAcceptsBodyWithinLimit(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	route := &testLengthLimitedRoute{}
+	fixture.x.Use(route)
+	handler := fixture.x.Build()
+
+	// Act.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/limited", strings.NewReader("Hello!"))
+	handler.ServeHTTP(w, r)
+
+	// Assert.
+	test.That(t, w.Result().StatusCode).IsEqualTo(http.StatusNoContent)
+	test.That(t, route.calls).IsEqualTo(1)
+}
+
+func TestSortTemplatesBySpecificity(t *testing.T) {
+	// Arrange.
+	templates := []string{
+		"/{collection}/{id}",
+		"/users/{id}",
+		"/users/{id:[0-9]+}",
+		"/{collection}/me",
+		"/users/me",
+		"/users/me/avatar.{ext}",
+		"/users/me/{attribute}",
+		"/users",
+	}
+
+	// Act.
+	sortTemplatesBySpecificity(templates, "/")
+
+	// Assert.
+	test.That(t, templates).HasEquivalentSequenceTo([]string{
+		"/users/me/avatar.{ext}",
+		"/users/me/{attribute}",
+		"/users/me",
+		"/users/{id:[0-9]+}",
+		"/users/{id}",
+		"/users",
+		"/{collection}/me",
+		"/{collection}/{id}",
+	})
+}
+
+func TestPurifyPathLeavesRegexTemplatesUnchanged(t *testing.T) {
+	// Arrange.
+	templates := []string{
+		`/users/{id:[0-9]+}`,
+		`/files/{path:.*}`,
+		`/tags/{tag:\w+}`,
+		`/codes/{code:[\d\-]{3,5}}`,
+		`/search/{term:%[0-9A-F]{2}.*}`,
+	}
+
+	for _, template := range templates {
+		// Act.
+		purified := purifyPath(template)
+
+		// Assert.
+		test.That(t, purified).IsEqualTo(template)
+	}
+}
+
+func TestPurifyPathNormalizesOutsideOfVariables(t *testing.T) {
+	// Arrange.
+	path := "  users\\{id:\\d+}\\avatar  "
+
+	// Act.
+	purified := purifyPath(path)
+
+	// Assert.
+	test.That(t, purified).IsEqualTo(`/users/{id:\d+}/avatar`)
+}
+
+func TestHandlerBuilderRegexTemplateWithBackslashMatches(t *testing.T) {
+	// Arrange.
+	fixture := SetupHandlerBuilderFixture()
+	fixture.x.Use(&testOverlappingRoute{path: `/tags/{tag:\w+}`})
+	handler := fixture.x.Build()
+
+	// Act.
+	w1 := httptest.NewRecorder()
+	handler.ServeHTTP(w1, httptest.NewRequest(http.MethodGet, "/tags/golang", nil))
+
+	w2 := httptest.NewRecorder()
+	handler.ServeHTTP(w2, httptest.NewRequest(http.MethodGet, "/tags/go-lang", nil))
+
+	// Assert.
+	test.That(t, w1.Result().StatusCode).IsEqualTo(http.StatusOK)
+	test.That(t, w2.Result().StatusCode).IsEqualTo(http.StatusNotFound)
 }
 
 // -----------------------------------------------------------------------------
 
-type testRoute struct{}
+type testRoute struct{}
+
+var _ Route = &testRoute{}
+
+func (*testRoute) Method() string {
+	return http.MethodGet
+}
+
+func (*testRoute) Path() string {
+	return "/test/{val1}"
+}
+
+func (*testRoute) Middleware() []Middleware {
+	return []Middleware{
+		&testMiddleware{},
+	}
+}
+
+func (*testRoute) Handle(ctx *Context) {
+	val1 := ctx.GetPathParameter("val1")
+	val2 := ctx.GetQueryParameter("val2")
+	val3 := ctx.GetMiddlewareArtifact("extra")
+
+	if val3 == "panic" {
+		panic("something to panic about")
+	}
+
+	ctx.RespondWithJSON(http.StatusOK, &testResponseModel{
+		Message: fmt.Sprintf("%v %v%v", val1, val2, val3),
+	})
+}
+
+type testMiddleware struct{}
+
+var _ Middleware = &testMiddleware{}
+
+func (*testMiddleware) Handle(ctx *Context) bool {
+	ctx.SetMiddlewareArtifact("extra", ctx.Request().Header.Get("X-Extra"))
+	return true
+}
+
+type testConstrainedRoute struct{}
+
+var _ ConstrainedRoute = &testConstrainedRoute{}
+var _ NamedRoute = &testConstrainedRoute{}
+
+func (*testConstrainedRoute) Method() string {
+	return http.MethodGet
+}
+
+func (*testConstrainedRoute) Path() string {
+	return "/users/{id}"
+}
+
+func (*testConstrainedRoute) Name() string {
+	return "getUser"
+}
+
+func (*testConstrainedRoute) Middleware() []Middleware {
+	return nil
+}
+
+func (*testConstrainedRoute) Constraints() map[string]string {
+	return map[string]string{
+		"id": "[0-9]+",
+	}
+}
+
+func (*testConstrainedRoute) Handle(ctx *Context) {
+	ctx.RespondWithJSON(http.StatusOK, &testResponseModel{
+		Message: ctx.GetPathParameter("id"),
+	})
+}
+
+type testHostRoute struct {
+	message string
+}
+
+var _ Route = &testHostRoute{}
+
+func (*testHostRoute) Method() string {
+	return http.MethodGet
+}
+
+func (*testHostRoute) Path() string {
+	return "/whoami"
+}
+
+func (*testHostRoute) Middleware() []Middleware {
+	return nil
+}
+
+func (route *testHostRoute) Handle(ctx *Context) {
+	message := strings.TrimSpace(fmt.Sprintf("%v %v", route.message, ctx.GetPathParameter("tenant")))
+	ctx.RespondWithJSON(http.StatusOK, &testResponseModel{
+		Message: message,
+	})
+}
+
+type testBufferedRoute struct{}
+
+var _ Route = &testBufferedRoute{}
+
+func (*testBufferedRoute) Method() string {
+	return http.MethodGet
+}
+
+func (*testBufferedRoute) Path() string {
+	return "/buffered"
+}
+
+func (*testBufferedRoute) Middleware() []Middleware {
+	return []Middleware{
+		&testReplaceBodyMiddleware{},
+	}
+}
+
+func (*testBufferedRoute) Handle(ctx *Context) {
+	ctx.RespondWithJSON(http.StatusOK, &testResponseModel{
+		Message: "Hello, World!",
+	})
+}
+
+type testFinalizedRoute struct{}
+
+var _ Route = &testFinalizedRoute{}
+
+func (*testFinalizedRoute) Method() string {
+	return http.MethodGet
+}
+
+func (*testFinalizedRoute) Path() string {
+	return "/finalized"
+}
+
+func (*testFinalizedRoute) Middleware() []Middleware {
+	return []Middleware{
+		&testStatusHeaderMiddleware{},
+	}
+}
+
+func (*testFinalizedRoute) Handle(ctx *Context) {
+	ctx.RespondWithJSON(http.StatusCreated, &testResponseModel{
+		Message: "Hello, World!",
+	})
+}
+
+type testStatusHeaderMiddleware struct{}
+
+var _ FinalizingMiddleware = &testStatusHeaderMiddleware{}
+
+func (*testStatusHeaderMiddleware) Handle(ctx *Context) bool {
+	return true
+}
+
+func (*testStatusHeaderMiddleware) Finalize(ctx *Context, statusCode int) {
+	ctx.Header().Set("X-Finalized-Status", strconv.Itoa(statusCode))
+}
+
+type testReplaceBodyMiddleware struct{}
+
+var _ AfterMiddleware = &testReplaceBodyMiddleware{}
+
+func (*testReplaceBodyMiddleware) Handle(ctx *Context) bool {
+	ctx.BufferResponse()
+	return true
+}
+
+func (*testReplaceBodyMiddleware) After(ctx *Context) {
+	brw := ctx.BufferResponse()
+	body := strings.Replace(string(brw.Body()), "Hello, World!", "Goodbye!", 1)
+	brw.SetBody([]byte(body))
+}
+
+type testNamedMiddlewareRoute struct {
+	path            string
+	namedMiddleware []string
+	calls           *[]string
+}
+
+var _ NamedMiddlewareRoute = &testNamedMiddlewareRoute{}
+
+func (*testNamedMiddlewareRoute) Method() string {
+	return http.MethodGet
+}
+
+func (route *testNamedMiddlewareRoute) Path() string {
+	return route.path
+}
+
+func (route *testNamedMiddlewareRoute) NamedMiddleware() []string {
+	return route.namedMiddleware
+}
+
+func (route *testNamedMiddlewareRoute) Middleware() []Middleware {
+	return []Middleware{
+		&testRecordingMiddleware{name: "own", calls: route.calls},
+	}
+}
+
+func (route *testNamedMiddlewareRoute) Handle(ctx *Context) {
+	*route.calls = append(*route.calls, route.path)
+	ctx.Respond(http.StatusNoContent)
+}
+
+type testRecordingMiddleware struct {
+	name  string
+	calls *[]string
+}
+
+var _ Middleware = &testRecordingMiddleware{}
+
+func (mw *testRecordingMiddleware) Handle(ctx *Context) bool {
+	*mw.calls = append(*mw.calls, mw.name)
+	return true
+}
 
-var _ Route = &testRoute{}
+type testMisbehavingRoute struct{}
 
-func (*testRoute) Method() string {
+var _ Route = &testMisbehavingRoute{}
+
+func (*testMisbehavingRoute) Method() string {
 	return http.MethodGet
 }
 
-func (*testRoute) Path() string {
-	return "/test/{val1}"
+func (*testMisbehavingRoute) Path() string {
+	return "/misbehaving"
 }
 
-func (*testRoute) Middleware() []Middleware {
+func (*testMisbehavingRoute) Middleware() []Middleware {
 	return []Middleware{
-		&testMiddleware{},
+		&testHaltingMiddleware{},
 	}
 }
 
-func (*testRoute) Handle(ctx *Context) {
-	val1 := ctx.GetPathParameter("val1")
-	val2 := ctx.GetQueryParameter("val2")
-	val3 := ctx.GetMiddlewareArtifact("extra")
+func (*testMisbehavingRoute) Handle(ctx *Context) {
+	ctx.Respond(http.StatusOK)
+}
 
-	if val3 == "panic" {
-		panic("something to panic about")
+type testHaltingMiddleware struct{}
+
+var _ Middleware = &testHaltingMiddleware{}
+
+func (*testHaltingMiddleware) Handle(ctx *Context) bool {
+	return false
+}
+
+type testOverlappingRoute struct {
+	path string
+}
+
+var _ Route = &testOverlappingRoute{}
+
+func (*testOverlappingRoute) Method() string {
+	return http.MethodGet
+}
+
+func (route *testOverlappingRoute) Path() string {
+	return route.path
+}
+
+func (*testOverlappingRoute) Middleware() []Middleware {
+	return nil
+}
+
+func (route *testOverlappingRoute) Handle(ctx *Context) {
+	ctx.RespondWithJSON(http.StatusOK, &testResponseModel{
+		Message: route.path,
+	})
+}
+
+type testRequestLoggerRoute struct {
+	logger logging.Logger
+}
+
+var _ Route = &testRequestLoggerRoute{}
+
+func (*testRequestLoggerRoute) Method() string {
+	return http.MethodGet
+}
+
+func (*testRequestLoggerRoute) Path() string {
+	return "/logged"
+}
+
+func (route *testRequestLoggerRoute) Middleware() []Middleware {
+	return []Middleware{
+		&testRequestLoggerMiddleware{logger: route.logger},
+	}
+}
+
+func (*testRequestLoggerRoute) Handle(ctx *Context) {
+	ctx.Respond(http.StatusNoContent)
+}
+
+type testRequestLoggerMiddleware struct {
+	logger logging.Logger
+}
+
+var _ Middleware = &testRequestLoggerMiddleware{}
+
+func (mw *testRequestLoggerMiddleware) Handle(ctx *Context) bool {
+	ctx.Container().Register(di.InstancePerContainer, func(c di.Container) (logging.Logger, error) {
+		return mw.logger, nil
+	})
+
+	return true
+}
+
+type testContentTypedRoute struct {
+	calls int
+}
+
+var _ ContentTypedRoute = &testContentTypedRoute{}
+
+func (*testContentTypedRoute) Method() string {
+	return http.MethodPost
+}
+
+func (*testContentTypedRoute) Path() string {
+	return "/typed"
+}
+
+func (*testContentTypedRoute) Accepts() []string {
+	return []string{"application/json"}
+}
+
+func (*testContentTypedRoute) Middleware() []Middleware {
+	return nil
+}
+
+func (route *testContentTypedRoute) Handle(ctx *Context) {
+	route.calls++
+	ctx.Respond(http.StatusNoContent)
+}
+
+type testFailingStreamRoute struct{}
+
+var _ Route = &testFailingStreamRoute{}
+
+func (*testFailingStreamRoute) Method() string {
+	return http.MethodGet
+}
+
+func (*testFailingStreamRoute) Path() string {
+	return "/stream"
+}
+
+func (*testFailingStreamRoute) Middleware() []Middleware {
+	return nil
+}
+
+func (*testFailingStreamRoute) Handle(ctx *Context) {
+	aw, err := ctx.StreamJSONArray(http.StatusOK)
+	if err != nil {
+		return
+	}
+
+	aw.Write(&testResponseModel{Message: "one"})
+	aw.Write(func() {})
+	aw.Close()
+}
+
+type testCorrelationIDRoute struct {
+	correlationID string
+}
+
+var _ Route = &testCorrelationIDRoute{}
+
+func (*testCorrelationIDRoute) Method() string {
+	return http.MethodGet
+}
+
+func (*testCorrelationIDRoute) Path() string {
+	return "/correlated"
+}
+
+func (*testCorrelationIDRoute) Middleware() []Middleware {
+	return nil
+}
+
+func (route *testCorrelationIDRoute) Handle(ctx *Context) {
+	route.correlationID = ctx.GetCorrelationID().String()
+	ctx.Respond(http.StatusNoContent)
+}
+
+type testLengthLimitedRoute struct {
+	calls int
+}
+
+var _ LengthLimitedRoute = &testLengthLimitedRoute{}
+
+func (*testLengthLimitedRoute) Method() string {
+	return http.MethodPost
+}
+
+func (*testLengthLimitedRoute) Path() string {
+	return "/limited"
+}
+
+func (*testLengthLimitedRoute) MaxContentLength() int64 {
+	return 8
+}
+
+func (*testLengthLimitedRoute) Middleware() []Middleware {
+	return nil
+}
+
+func (route *testLengthLimitedRoute) Handle(ctx *Context) {
+	route.calls++
+	ctx.Respond(http.StatusNoContent)
+}
+
+type testPanickingMiddlewareRoute struct{}
+
+var _ Route = &testPanickingMiddlewareRoute{}
+
+func (*testPanickingMiddlewareRoute) Method() string {
+	return http.MethodGet
+}
+
+func (*testPanickingMiddlewareRoute) Path() string {
+	return "/panicking"
+}
+
+func (*testPanickingMiddlewareRoute) Middleware() []Middleware {
+	return []Middleware{
+		&testMiddleware{},
+		&testPanickingMiddleware{},
 	}
+}
+
+func (*testPanickingMiddlewareRoute) Handle(ctx *Context) {
+	ctx.Respond(http.StatusOK)
+}
+
+type testPanickingMiddleware struct{}
+
+var _ Middleware = &testPanickingMiddleware{}
+
+func (*testPanickingMiddleware) Handle(ctx *Context) bool {
+	panic("something to panic about")
+}
+
+type testErrorPanickingRoute struct {
+	err error
+}
+
+var _ Route = &testErrorPanickingRoute{}
+
+func (*testErrorPanickingRoute) Method() string {
+	return http.MethodGet
+}
+
+func (*testErrorPanickingRoute) Path() string {
+	return "/error-panicking"
+}
+
+func (*testErrorPanickingRoute) Middleware() []Middleware {
+	return nil
+}
+
+func (route *testErrorPanickingRoute) Handle(ctx *Context) {
+	panic(route.err)
+}
+
+type testPanicError struct{}
+
+func (*testPanicError) Error() string {
+	return "a typed panic"
+}
+
+type testDeleteRoute struct {
+	calls  int
+	method string
+	path   string
+}
+
+var _ Route = &testDeleteRoute{}
+
+func (*testDeleteRoute) Method() string {
+	return http.MethodDelete
+}
+
+func (*testDeleteRoute) Path() string {
+	return "/things/{id}"
+}
+
+func (*testDeleteRoute) Middleware() []Middleware {
+	return nil
+}
+
+func (route *testDeleteRoute) Handle(ctx *Context) {
+	route.calls++
+	route.method = ctx.Method()
+	route.path = ctx.Path()
+	ctx.Respond(http.StatusNoContent)
+}
+
+type testMethodRoute struct {
+	method string
+}
+
+var _ Route = &testMethodRoute{}
+
+func (route *testMethodRoute) Method() string {
+	return route.method
+}
+
+func (*testMethodRoute) Path() string {
+	return "/methods"
+}
+
+func (*testMethodRoute) Middleware() []Middleware {
+	return nil
+}
+
+func (*testMethodRoute) Handle(ctx *Context) {
+	ctx.Respond(http.StatusNoContent)
+}
+
+type testCatchAllRoute struct{}
+
+var _ Route = &testCatchAllRoute{}
+
+func (*testCatchAllRoute) Method() string {
+	return http.MethodGet
+}
+
+func (*testCatchAllRoute) Path() string {
+	return `/files/{path:.*}`
+}
 
+func (*testCatchAllRoute) Middleware() []Middleware {
+	return nil
+}
+
+func (*testCatchAllRoute) Handle(ctx *Context) {
 	ctx.RespondWithJSON(http.StatusOK, &testResponseModel{
-		Message: fmt.Sprintf("%v %v%v", val1, val2, val3),
+		Message: ctx.GetPathParameter("path"),
 	})
 }
 
-type testMiddleware struct{}
+type testErrRoute struct {
+	err     error
+	respond bool
+}
 
-var _ Middleware = &testMiddleware{}
+var _ ErrRoute = &testErrRoute{}
+
+func (*testErrRoute) Method() string {
+	return http.MethodGet
+}
+
+func (*testErrRoute) Path() string {
+	return "/err-route"
+}
+
+func (*testErrRoute) Middleware() []Middleware {
+	return nil
+}
+
+func (route *testErrRoute) Handle(ctx *Context) error {
+	if route.respond {
+		ctx.RespondWithJSON(http.StatusOK, &testResponseModel{
+			Message: "Hello, World!",
+		})
+	}
+
+	return route.err
+}
+
+type testExtendedErrRoute struct {
+	calls int
+}
+
+var _ ErrRoute = &testExtendedErrRoute{}
+
+func (*testExtendedErrRoute) Method() string {
+	return http.MethodPut
+}
+
+func (*testExtendedErrRoute) Path() string {
+	return "/err-widgets/{id}"
+}
+
+func (*testExtendedErrRoute) Middleware() []Middleware {
+	return nil
+}
+
+func (*testExtendedErrRoute) Constraints() map[string]string {
+	return map[string]string{"id": "[0-9]+"}
+}
+
+func (*testExtendedErrRoute) Name() string {
+	return "putErrWidget"
+}
+
+func (*testExtendedErrRoute) Accepts() []string {
+	return []string{"application/json"}
+}
+
+func (*testExtendedErrRoute) Summary() string {
+	return "Replaces a widget, or fails."
+}
+
+func (*testExtendedErrRoute) RequestModel() interface{} {
+	return &testResponseModel{}
+}
+
+func (*testExtendedErrRoute) ResponseModel() interface{} {
+	return nil
+}
+
+func (route *testExtendedErrRoute) Handle(ctx *Context) error {
+	route.calls++
+	return nil
+}
+
+type testDefaultHeaderOverridingRoute struct{}
+
+var _ Route = &testDefaultHeaderOverridingRoute{}
+
+func (*testDefaultHeaderOverridingRoute) Method() string {
+	return http.MethodGet
+}
+
+func (*testDefaultHeaderOverridingRoute) Path() string {
+	return "/cacheable"
+}
+
+func (*testDefaultHeaderOverridingRoute) Middleware() []Middleware {
+	return nil
+}
+
+func (*testDefaultHeaderOverridingRoute) Handle(ctx *Context) {
+	ctx.SetHeader("Cache-Control", "max-age=60")
+	ctx.Respond(http.StatusNoContent)
+}
+
+type testAuthMiddleware struct{}
+
+var _ Middleware = &testAuthMiddleware{}
+
+func (*testAuthMiddleware) Handle(ctx *Context) bool {
+	if ctx.Request().Header.Get("Authorization") == "" {
+		ctx.Respond(http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
+
+func testHeaderSettingHTTPMiddleware(name string, calls *[]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*calls = append(*calls, name)
+			w.Header().Set("X-Wrapped-By", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type testEnvelopeMiddleware struct{}
+
+var _ Middleware = &testEnvelopeMiddleware{}
+
+func (*testEnvelopeMiddleware) Handle(ctx *Context) bool {
+	ctx.AddResponseTransformer(func(status int, body []byte) []byte {
+		return []byte(fmt.Sprintf(`{"status":%v,"data":%s}`, status, body))
+	})
 
-func (*testMiddleware) Handle(ctx *Context) bool {
-	ctx.SetMiddlewareArtifact("extra", ctx.Request().Header.Get("X-Extra"))
 	return true
 }
+
+type testRecordingLogger struct {
+	messages []string
+}
+
+var _ logging.Logger = &testRecordingLogger{}
+
+func (l *testRecordingLogger) Printf(format string, v ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+type testDocumentedRoute struct{}
+
+var _ DocumentedRoute = &testDocumentedRoute{}
+
+func (*testDocumentedRoute) Method() string {
+	return http.MethodPut
+}
+
+func (*testDocumentedRoute) Path() string {
+	return "/widgets/{id:[0-9]+}"
+}
+
+func (*testDocumentedRoute) Middleware() []Middleware {
+	return nil
+}
+
+func (*testDocumentedRoute) Summary() string {
+	return "Replaces a widget."
+}
+
+func (*testDocumentedRoute) RequestModel() interface{} {
+	return &testRequestModel{}
+}
+
+func (*testDocumentedRoute) ResponseModel() interface{} {
+	return &testResponseModel{}
+}
+
+func (*testDocumentedRoute) Handle(ctx *Context) {
+	ctx.Respond(http.StatusNoContent)
+}
@@ -0,0 +1,202 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const idempotencyMiddlewareArtifactName = "web.idempotency"
+
+// IdempotencyMiddleware is a Middleware that honours the Idempotency-Key
+// request header.  The first response for a key is captured and saved to the
+// IdempotencyStore, and is replayed for any subsequent requests with the same
+// key by the same caller.  Requests that arrive while a request with the same
+// key is still in flight receive a Conflict response.  Responses with a 5xx
+// status code are not saved, so that the request can be retried.  The
+// Set-Cookie and correlation ID headers of a response are never saved.
+type IdempotencyMiddleware struct {
+	store IdempotencyStore
+	scope func(ctx *Context) string
+}
+
+type idempotencyState struct {
+	key string
+	rrw *recordingResponseWriter
+}
+
+var _ AfterMiddleware = &IdempotencyMiddleware{}
+
+// NewIdempotencyMiddleware creates a new IdempotencyMiddleware backed by the
+// provided store.  Keys are scoped to the client IP address and Authorization
+// header of the request, so that callers cannot replay each other's responses.
+func NewIdempotencyMiddleware(store IdempotencyStore) *IdempotencyMiddleware {
+	return NewIdempotencyMiddlewareWithScope(store, defaultIdempotencyScope)
+}
+
+// NewIdempotencyMiddlewareWithScope creates a new IdempotencyMiddleware backed
+// by the provided store, with keys scoped to the caller identified by scope.
+// scope should return a value unique to the caller, such as an authenticated
+// principal.
+func NewIdempotencyMiddlewareWithScope(store IdempotencyStore, scope func(ctx *Context) string) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{
+		store: store,
+		scope: scope,
+	}
+}
+
+// Handle replays the stored response if one exists for the Idempotency-Key of
+// the request, or reserves the key and begins capturing the response if not.
+// Requests without an Idempotency-Key are passed through untouched.
+func (m *IdempotencyMiddleware) Handle(ctx *Context) bool {
+	idempotencyKey := strings.TrimSpace(ctx.r.Header.Get("Idempotency-Key"))
+	if idempotencyKey == "" {
+		return true
+	}
+
+	key := fmt.Sprintf("%v %v %v %v", m.scope(ctx), ctx.r.Method, ctx.r.URL.Path, idempotencyKey)
+
+	reserved, err := m.store.Reserve(key)
+	if err != nil {
+		ctx.InternalServerError(err)
+		return false
+	}
+
+	if !reserved {
+		return m.replay(ctx, key, idempotencyKey)
+	}
+
+	// If the response is already being buffered, the response is recorded as
+	// the buffer commits it, so that changes made to the buffer are captured.
+	var rrw *recordingResponseWriter
+	if ctx.brw != nil && !ctx.brw.HasCommitted() {
+		rrw = newRecordingResponseWriter(ctx.brw.w)
+		ctx.brw.w = rrw
+	} else {
+		rrw = newRecordingResponseWriter(ctx.w)
+		ctx.w = rrw
+	}
+
+	ctx.SetMiddlewareArtifact(idempotencyMiddlewareArtifactName, &idempotencyState{
+		key: key,
+		rrw: rrw,
+	})
+
+	return true
+}
+
+// After saves the captured response to the store, or releases the reservation
+// if the response should not be saved.  If the response is buffered, it is
+// saved once the buffer has been committed, so that the saved response
+// includes any changes made to it by transformers or FinalizingMiddleware.
+func (m *IdempotencyMiddleware) After(ctx *Context) {
+	state, ok := ctx.GetMiddlewareArtifact(idempotencyMiddlewareArtifactName).(*idempotencyState)
+	if !ok {
+		return
+	}
+
+	if ctx.w == state.rrw {
+		ctx.w = state.rrw.w
+	}
+
+	ctx.whenResponseComplete(func() {
+		m.save(ctx, state)
+	})
+}
+
+func (m *IdempotencyMiddleware) save(ctx *Context, state *idempotencyState) {
+	statusCode := state.rrw.statusCode
+	if statusCode == 0 || statusCode >= http.StatusInternalServerError {
+		m.store.Release(state.key)
+		return
+	}
+
+	m.store.Save(state.key, &IdempotentResponse{
+		StatusCode: statusCode,
+		Header:     replayableHeader(ctx, state.rrw.header),
+		Body:       state.rrw.body.Bytes(),
+	})
+}
+
+func (m *IdempotencyMiddleware) replay(ctx *Context, key string, idempotencyKey string) bool {
+	response, err := m.store.Get(key)
+	if err != nil {
+		ctx.InternalServerError(err)
+		return false
+	}
+
+	if response == nil {
+		problem := ctx.getProblemDetailsForIdempotencyKeyInFlight(idempotencyKey)
+		ctx.respondWithProblem(http.StatusConflict, problem)
+		return false
+	}
+
+	for name, values := range replayableHeader(ctx, response.Header) {
+		ctx.w.Header()[name] = append([]string(nil), values...)
+	}
+
+	ctx.w.Header().Set("Idempotent-Replayed", "true")
+	ctx.Respond(response.StatusCode)
+	ctx.w.Write(response.Body)
+
+	return false
+}
+
+func defaultIdempotencyScope(ctx *Context) string {
+	authorization := ctx.r.Header.Get("Authorization")
+	if authorization == "" {
+		return ctx.ClientIP()
+	}
+
+	hash := sha256.Sum256([]byte(authorization))
+	return fmt.Sprintf("%v;%v", ctx.ClientIP(), hex.EncodeToString(hash[:]))
+}
+
+func replayableHeader(ctx *Context, header http.Header) http.Header {
+	replayable := header.Clone()
+	replayable.Del("Set-Cookie")
+	replayable.Del(ctx.correlationIDHeader())
+
+	return replayable
+}
+
+type recordingResponseWriter struct {
+	w          http.ResponseWriter
+	statusCode int
+	header     http.Header
+	body       *bytes.Buffer
+}
+
+var _ http.ResponseWriter = &recordingResponseWriter{}
+
+func newRecordingResponseWriter(w http.ResponseWriter) *recordingResponseWriter {
+	return &recordingResponseWriter{
+		w:    w,
+		body: &bytes.Buffer{},
+	}
+}
+
+func (rrw *recordingResponseWriter) Header() http.Header {
+	return rrw.w.Header()
+}
+
+func (rrw *recordingResponseWriter) Write(b []byte) (int, error) {
+	if rrw.statusCode == 0 {
+		rrw.WriteHeader(http.StatusOK)
+	}
+
+	rrw.body.Write(b)
+	return rrw.w.Write(b)
+}
+
+func (rrw *recordingResponseWriter) WriteHeader(statusCode int) {
+	if rrw.statusCode == 0 {
+		rrw.statusCode = statusCode
+		rrw.header = rrw.w.Header().Clone()
+	}
+
+	rrw.w.WriteHeader(statusCode)
+}
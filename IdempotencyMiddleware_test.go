@@ -0,0 +1,279 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ljpx/di"
+	"github.com/ljpx/logging"
+	"github.com/ljpx/test"
+)
+
+type IdempotencyMiddlewareFixture struct {
+	x       *IdempotencyMiddleware
+	store   *MemoryIdempotencyStore
+	route   *testIdempotentRoute
+	handler http.Handler
+}
+
+func SetupIdempotencyMiddlewareFixture() *IdempotencyMiddlewareFixture {
+	fixture := &IdempotencyMiddlewareFixture{}
+	fixture.store = NewMemoryIdempotencyStore(time.Hour)
+	fixture.x = NewIdempotencyMiddleware(fixture.store)
+	fixture.route = &testIdempotentRoute{middleware: fixture.x}
+
+	builder := NewHandlerBuilder(di.NewContainer(), logging.NewDummyLogger(), &Config{
+		ProblemDetailsTypePrefix: "https://testi.ng",
+	})
+
+	builder.Use(fixture.route)
+	fixture.handler = builder.Build()
+
+	return fixture
+}
+
+func (fixture *IdempotencyMiddlewareFixture) post(idempotencyKey string) *http.Response {
+	return fixture.postAs("192.0.2.1:1234", "", idempotencyKey)
+}
+
+func (fixture *IdempotencyMiddlewareFixture) postAs(remoteAddr string, authorization string, idempotencyKey string) *http.Response {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	r.RemoteAddr = remoteAddr
+	r.Header.Set("Idempotency-Key", idempotencyKey)
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+
+	fixture.handler.ServeHTTP(w, r)
+
+	return w.Result()
+}
+
+func TestIdempotencyMiddlewareFirstRequestStores(t *testing.T) {
+	// Arrange.
+	fixture := SetupIdempotencyMiddlewareFixture()
+
+	// Act.
+	res := fixture.post("abc")
+
+	// Assert.
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusCreated)
+	test.That(t, fixture.route.calls).IsEqualTo(1)
+
+	stored, err := fixture.store.Get("192.0.2.1 POST /orders abc")
+	test.That(t, err).IsNil()
+	test.That(t, stored).IsNotNil()
+	test.That(t, stored.StatusCode).IsEqualTo(http.StatusCreated)
+	test.That(t, stored.Header.Get("X-Order")).IsEqualTo("order-1")
+	test.That(t, string(stored.Body)).IsEqualTo(`{"message":"order-1"}`)
+	test.That(t, stored.Header.Get("Set-Cookie")).IsEqualTo("")
+	test.That(t, stored.Header.Get("Correlation-ID")).IsEqualTo("")
+}
+
+func TestIdempotencyMiddlewareDuplicateReplays(t *testing.T) {
+	// Arrange.
+	fixture := SetupIdempotencyMiddlewareFixture()
+	first := fixture.post("abc")
+
+	// Act.
+	second := fixture.post("abc")
+
+	// Assert.
+	test.That(t, fixture.route.calls).IsEqualTo(1)
+	test.That(t, second.StatusCode).IsEqualTo(http.StatusCreated)
+	test.That(t, second.Header.Get("X-Order")).IsEqualTo("order-1")
+	test.That(t, second.Header.Get("Idempotent-Replayed")).IsEqualTo("true")
+	test.That(t, second.Header.Get("Correlation-ID")).IsNotEqualTo(first.Header.Get("Correlation-ID"))
+	test.That(t, second.Header.Get("Set-Cookie")).IsEqualTo("")
+
+	resModel := &testResponseModel{}
+	err := UnmarshalFromResponse(second, resModel)
+	test.That(t, err).IsNil()
+	test.That(t, resModel.Message).IsEqualTo("order-1")
+}
+
+func TestIdempotencyMiddlewareDifferentKeys(t *testing.T) {
+	// Arrange.
+	fixture := SetupIdempotencyMiddlewareFixture()
+	fixture.post("abc")
+
+	// Act.
+	res := fixture.post("def")
+
+	// Assert.
+	test.That(t, fixture.route.calls).IsEqualTo(2)
+	test.That(t, res.Header.Get("X-Order")).IsEqualTo("order-2")
+}
+
+func TestIdempotencyMiddlewareSameKeyDifferentClients(t *testing.T) {
+	// Arrange.
+	fixture := SetupIdempotencyMiddlewareFixture()
+	fixture.postAs("192.0.2.1:1234", "", "abc")
+
+	// Act.
+	res := fixture.postAs("198.51.100.7:1234", "", "abc")
+
+	// Assert.
+	test.That(t, fixture.route.calls).IsEqualTo(2)
+	test.That(t, res.Header.Get("X-Order")).IsEqualTo("order-2")
+	test.That(t, res.Header.Get("Idempotent-Replayed")).IsEqualTo("")
+}
+
+func TestIdempotencyMiddlewareSameKeyDifferentPrincipals(t *testing.T) {
+	// Arrange.
+	fixture := SetupIdempotencyMiddlewareFixture()
+	fixture.postAs("192.0.2.1:1234", "Bearer alice", "abc")
+
+	// Act.
+	res := fixture.postAs("192.0.2.1:1234", "Bearer bob", "abc")
+
+	// Assert.
+	test.That(t, fixture.route.calls).IsEqualTo(2)
+	test.That(t, res.Header.Get("X-Order")).IsEqualTo("order-2")
+}
+
+func TestIdempotencyMiddlewareCustomScope(t *testing.T) {
+	// Arrange.
+	fixture := SetupIdempotencyMiddlewareFixture()
+	fixture.route.middleware = NewIdempotencyMiddlewareWithScope(fixture.store, func(ctx *Context) string {
+		return "tenant-1"
+	})
+
+	fixture.postAs("192.0.2.1:1234", "", "abc")
+
+	// Act.
+	res := fixture.postAs("198.51.100.7:1234", "", "abc")
+
+	// Assert.
+	test.That(t, fixture.route.calls).IsEqualTo(1)
+	test.That(t, res.Header.Get("Idempotent-Replayed")).IsEqualTo("true")
+
+	stored, err := fixture.store.Get("tenant-1 POST /orders abc")
+	test.That(t, err).IsNil()
+	test.That(t, stored).IsNotNil()
+}
+
+func TestIdempotencyMiddlewareReplaysTransformedResponse(t *testing.T) {
+	// Arrange.
+	fixture := SetupIdempotencyMiddlewareFixture()
+	fixture.route.transform = true
+	fixture.post("abc")
+
+	// Act.
+	res := fixture.post("abc")
+
+	// Assert.
+	test.That(t, fixture.route.calls).IsEqualTo(1)
+	test.That(t, res.Header.Get("Idempotent-Replayed")).IsEqualTo("true")
+
+	resModel := &testResponseModel{}
+	err := UnmarshalFromResponse(res, resModel)
+	test.That(t, err).IsNil()
+	test.That(t, resModel.Message).IsEqualTo("ORDER-1")
+}
+
+func TestIdempotencyMiddlewareReplaysFinalizedResponse(t *testing.T) {
+	// Arrange.
+	fixture := SetupIdempotencyMiddlewareFixture()
+	fixture.route.extra = []Middleware{&testStatusHeaderMiddleware{}}
+	fixture.post("abc")
+
+	// Act.
+	res := fixture.post("abc")
+
+	// Assert.
+	test.That(t, fixture.route.calls).IsEqualTo(1)
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusCreated)
+	test.That(t, res.Header.Get("Idempotent-Replayed")).IsEqualTo("true")
+	test.That(t, res.Header.Get("X-Finalized-Status")).IsEqualTo("201")
+	test.That(t, res.Header.Get("X-Order")).IsEqualTo("order-1")
+}
+
+func TestIdempotencyMiddlewareInFlight(t *testing.T) {
+	// Arrange.
+	fixture := SetupIdempotencyMiddlewareFixture()
+	fixture.store.Reserve("192.0.2.1 POST /orders abc")
+
+	// Act.
+	res := fixture.post("abc")
+
+	// Assert.
+	test.That(t, fixture.route.calls).IsEqualTo(0)
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusConflict)
+}
+
+func TestIdempotencyMiddlewareDoesNotStoreServerErrors(t *testing.T) {
+	// Arrange.
+	fixture := SetupIdempotencyMiddlewareFixture()
+	fixture.route.fail = true
+	fixture.post("abc")
+	fixture.route.fail = false
+
+	// Act.
+	res := fixture.post("abc")
+
+	// Assert.
+	test.That(t, fixture.route.calls).IsEqualTo(2)
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusCreated)
+}
+
+func TestIdempotencyMiddlewareWithoutKey(t *testing.T) {
+	// Arrange.
+	fixture := SetupIdempotencyMiddlewareFixture()
+
+	// Act.
+	fixture.post("")
+	fixture.post("")
+
+	// Assert.
+	test.That(t, fixture.route.calls).IsEqualTo(2)
+}
+
+// -----------------------------------------------------------------------------
+
+type testIdempotentRoute struct {
+	middleware Middleware
+	extra      []Middleware
+	calls      int
+	fail       bool
+	transform  bool
+}
+
+var _ Route = &testIdempotentRoute{}
+
+func (*testIdempotentRoute) Method() string {
+	return http.MethodPost
+}
+
+func (*testIdempotentRoute) Path() string {
+	return "/orders"
+}
+
+func (route *testIdempotentRoute) Middleware() []Middleware {
+	return append([]Middleware{route.middleware}, route.extra...)
+}
+
+func (route *testIdempotentRoute) Handle(ctx *Context) {
+	route.calls++
+
+	if route.fail {
+		ctx.InternalServerError(fmt.Errorf("failed"))
+		return
+	}
+
+	if route.transform {
+		ctx.AddResponseTransformer(func(status int, body []byte) []byte {
+			return bytes.ToUpper(body)
+		})
+	}
+
+	order := fmt.Sprintf("order-%v", route.calls)
+	ctx.Header().Set("X-Order", order)
+	ctx.Header().Set("Set-Cookie", fmt.Sprintf("session=%v", order))
+	ctx.RespondWithJSON(http.StatusCreated, &testResponseModel{Message: order})
+}
@@ -0,0 +1,25 @@
+package web
+
+import "net/http"
+
+// IdempotencyStore defines the methods that any store backing an
+// IdempotencyMiddleware must implement.  Implementations must be thread-safe.
+//
+// Reserve marks the key as in-flight.  It returns false if the key is already
+// in-flight or has a stored response.  Get returns the stored response for the
+// key, or nil if there is none.  Save stores the response for a reserved key,
+// and Release removes the reservation for a key without storing a response.
+type IdempotencyStore interface {
+	Reserve(key string) (bool, error)
+	Get(key string) (*IdempotentResponse, error)
+	Save(key string, response *IdempotentResponse) error
+	Release(key string) error
+}
+
+// IdempotentResponse is a response that has been captured for replay by an
+// IdempotencyMiddleware.
+type IdempotentResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// JSONArrayWriter writes the elements of a JSON array to a response one at a
+// time, so that the whole array never has to be held in memory.  It is created
+// with Context.StreamJSONArray, which writes the opening bracket, and the array
+// is completed with Close.  The response is flushed every StreamFlushInterval
+// elements, and can be flushed explicitly with Flush.  If writing an element fails, the status code can no
+// longer be changed, so the error is logged to the logging.Logger registered in
+// the container, or to the logger of the HandlerBuilder if there is none, and
+// the array is deliberately left unclosed, making the truncation detectable by
+// the client.
+type JSONArrayWriter struct {
+	ctx     *Context
+	w       http.ResponseWriter
+	flusher http.Flusher
+	count   int
+	err     error
+}
+
+func newJSONArrayWriter(ctx *Context) *JSONArrayWriter {
+	flusher, _ := ctx.w.(http.Flusher)
+
+	return &JSONArrayWriter{
+		ctx:     ctx,
+		w:       ctx.w,
+		flusher: flusher,
+	}
+}
+
+// Write encodes the provided value as the next element of the array.  Once a
+// Write has failed, or the request has been cancelled, all further calls to
+// Write return an error without writing anything.
+func (aw *JSONArrayWriter) Write(v interface{}) error {
+	if aw.err != nil {
+		return aw.err
+	}
+
+	if err := aw.ctx.r.Context().Err(); err != nil {
+		return aw.fail(err)
+	}
+
+	rawJSON, err := json.Marshal(v)
+	if err != nil {
+		return aw.fail(err)
+	}
+
+	if aw.count > 0 {
+		rawJSON = append([]byte(","), rawJSON...)
+	}
+
+	_, err = aw.w.Write(rawJSON)
+	if err != nil {
+		return aw.fail(err)
+	}
+
+	aw.count++
+	if aw.count%StreamFlushInterval == 0 {
+		aw.Flush()
+	}
+
+	return nil
+}
+
+// Close writes the closing bracket of the array and flushes the response.  If
+// a Write has failed, the array is left unclosed and the error is returned.
+func (aw *JSONArrayWriter) Close() error {
+	if aw.err != nil {
+		return aw.err
+	}
+
+	_, err := aw.w.Write([]byte("]"))
+	if err != nil {
+		return aw.fail(err)
+	}
+
+	aw.Flush()
+	return nil
+}
+
+// Flush sends any buffered elements to the client, if the underlying
+// http.ResponseWriter supports it.
+func (aw *JSONArrayWriter) Flush() {
+	if aw.flusher != nil {
+		aw.flusher.Flush()
+	}
+}
+
+func (aw *JSONArrayWriter) fail(err error) error {
+	aw.err = fmt.Errorf("streaming the JSON array failed after %v elements: %w", aw.count, err)
+	logStreamFailure(aw.ctx, aw.err)
+	return aw.err
+}
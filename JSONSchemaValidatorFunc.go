@@ -0,0 +1,7 @@
+package web
+
+// JSONSchemaValidatorFunc validates a JSON document against a JSON Schema.  It
+// returns a description of each way in which the document violates the schema,
+// or an empty slice if the document is valid.  The error return value is
+// reserved for failures of the validator itself, such as an invalid schema.
+type JSONSchemaValidatorFunc func(schema []byte, document []byte) ([]string, error)
@@ -0,0 +1,11 @@
+package web
+
+// LengthLimitedRoute is an optional interface that a Route can implement to
+// declare the maximum content length of its requests.  For POST, PUT and PATCH
+// requests, the HandlerBuilder asserts the content length of the request with
+// Context.AssertContentLength after the middleware for the route have run, and
+// before the route is handled.
+type LengthLimitedRoute interface {
+	Route
+	MaxContentLength() int64
+}
@@ -1,7 +1,9 @@
 package web
 
 import (
+	"io"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -26,6 +28,40 @@ func NewMeasuredResponseWriter(w http.ResponseWriter) *MeasuredResponseWriter {
 }
 
 var _ http.ResponseWriter = &MeasuredResponseWriter{}
+var _ http.Flusher = &MeasuredResponseWriter{}
+
+var measuredResponseWriterPool = sync.Pool{
+	New: func() interface{} {
+		return &MeasuredResponseWriter{}
+	},
+}
+
+// AcquireMeasuredResponseWriter retrieves a MeasuredResponseWriter from a pool,
+// resetting it to wrap the provided http.ResponseWriter.  It should be returned
+// to the pool with ReleaseMeasuredResponseWriter once it is no longer in use.
+func AcquireMeasuredResponseWriter(w http.ResponseWriter) *MeasuredResponseWriter {
+	mrw := measuredResponseWriterPool.Get().(*MeasuredResponseWriter)
+	mrw.Reset(w)
+
+	return mrw
+}
+
+// ReleaseMeasuredResponseWriter returns a MeasuredResponseWriter to the pool.
+// The MeasuredResponseWriter must not be used after it has been released.
+func ReleaseMeasuredResponseWriter(mrw *MeasuredResponseWriter) {
+	mrw.Reset(nil)
+	measuredResponseWriterPool.Put(mrw)
+}
+
+// Reset resets the MeasuredResponseWriter to the state of a newly created
+// MeasuredResponseWriter wrapping the provided http.ResponseWriter.
+func (mrw *MeasuredResponseWriter) Reset(w http.ResponseWriter) {
+	mrw.w = w
+	mrw.startTime = time.Now()
+	mrw.statusCode = 0
+	mrw.volume = 0
+	mrw.hasWrittenHeaders = false
+}
 
 // Header simply returns the headers of the underlying response writer.
 func (mrw *MeasuredResponseWriter) Header() http.Header {
@@ -33,14 +69,42 @@ func (mrw *MeasuredResponseWriter) Header() http.Header {
 }
 
 // Write writes to the underlying response writer, recording the number of bytes
-// successfully written.
+// successfully written.  If WriteHeader has not yet been called, it is called
+// with http.StatusOK.
 func (mrw *MeasuredResponseWriter) Write(b []byte) (int, error) {
+	if !mrw.hasWrittenHeaders {
+		mrw.WriteHeader(http.StatusOK)
+	}
+
 	n, err := mrw.w.Write(b)
 	mrw.volume += int64(n)
 
 	return n, err
 }
 
+// ReadFrom copies from the provided reader to the underlying response writer,
+// recording the number of bytes successfully written.  If the underlying
+// response writer implements io.ReaderFrom, it is used to perform the copy.  If
+// WriteHeader has not yet been called, it is called with http.StatusOK.
+func (mrw *MeasuredResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !mrw.hasWrittenHeaders {
+		mrw.WriteHeader(http.StatusOK)
+	}
+
+	var n int64
+	var err error
+
+	if readerFrom, ok := mrw.w.(io.ReaderFrom); ok {
+		n, err = readerFrom.ReadFrom(r)
+	} else {
+		n, err = io.Copy(mrw.w, r)
+	}
+
+	mrw.volume += n
+
+	return n, err
+}
+
 // WriteHeader records and writes the header if it has not already been written.
 func (mrw *MeasuredResponseWriter) WriteHeader(statusCode int) {
 	if mrw.hasWrittenHeaders {
@@ -52,6 +116,14 @@ func (mrw *MeasuredResponseWriter) WriteHeader(statusCode int) {
 	mrw.hasWrittenHeaders = true
 }
 
+// Flush sends any buffered data to the client, if the underlying
+// http.ResponseWriter supports it.
+func (mrw *MeasuredResponseWriter) Flush() {
+	if flusher, ok := mrw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 // StatusCode returns the status code that was written for the response.  If the
 // status code is yet to be written, or WriteHeader was never explicitly called,
 // StatusCode will return http.StatusOK.
@@ -69,8 +141,10 @@ func (mrw *MeasuredResponseWriter) HasWrittenHeaders() bool {
 }
 
 // Duration returns the duration between the start of the request and now.
+// Durations under 5ms are reported as zero - use RawDuration for the exact
+// duration.
 func (mrw *MeasuredResponseWriter) Duration() time.Duration {
-	dur := time.Now().Sub(mrw.startTime)
+	dur := mrw.RawDuration()
 
 	if dur < time.Millisecond*5 {
 		dur = time.Duration(0)
@@ -79,6 +153,18 @@ func (mrw *MeasuredResponseWriter) Duration() time.Duration {
 	return dur
 }
 
+// StartTime returns the time at which the MeasuredResponseWriter was created,
+// which is treated as the start of the request.
+func (mrw *MeasuredResponseWriter) StartTime() time.Time {
+	return mrw.startTime
+}
+
+// RawDuration returns the exact duration between the start of the request and
+// now.
+func (mrw *MeasuredResponseWriter) RawDuration() time.Duration {
+	return time.Now().Sub(mrw.startTime)
+}
+
 // Volume returns the number of bytes written to the response writer body.
 func (mrw *MeasuredResponseWriter) Volume() int64 {
 	return mrw.volume
@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -97,6 +98,30 @@ func TestMeasuredResponseWriterShouldReturnTrueForHasWrittenHeaders(t *testing.T
 	test.That(t, hasWrittenHeaders).IsTrue()
 }
 
+func TestMeasuredResponseWriterShouldWriteHeadersImplicitly(t *testing.T) {
+	// Arrange.
+	fixture := SetupMeasuredResponseWriterFixture()
+	fixture.x.Write([]byte("Hello, World!"))
+
+	// Act.
+	hasWrittenHeaders := fixture.x.HasWrittenHeaders()
+
+	// Assert.
+	test.That(t, hasWrittenHeaders).IsTrue()
+	test.That(t, fixture.x.StatusCode()).IsEqualTo(http.StatusOK)
+}
+
+func TestMeasuredResponseWriterShouldFlushUnderlyingWriter(t *testing.T) {
+	// Arrange.
+	fixture := SetupMeasuredResponseWriterFixture()
+
+	// Act.
+	fixture.x.Flush()
+
+	// Assert.
+	test.That(t, fixture.w.Flushed).IsTrue()
+}
+
 func TestMeasuredResponseWriterShouldReturnCorrectDuration(t *testing.T) {
 	// Arrange.
 	fixture := SetupMeasuredResponseWriterFixture()
@@ -113,3 +138,84 @@ func TestMeasuredResponseWriterShouldReturnCorrectDuration(t *testing.T) {
 	test.That(t, actual).IsGreaterThanOrEqualTo(expected - delta)
 	test.That(t, actual).IsLessThanOrEqualTo(expected + delta)
 }
+
+func TestMeasuredResponseWriterShouldReturnUnflooredRawDuration(t *testing.T) {
+	// Arrange.
+	fixture := SetupMeasuredResponseWriterFixture()
+	fixture.x.startTime = time.Now().Add(-time.Millisecond * 2)
+
+	// Act.
+	rawDur := fixture.x.RawDuration()
+
+	// Assert.
+	actual := float64(rawDur)
+	test.That(t, actual).IsGreaterThanOrEqualTo(float64(time.Millisecond * 2))
+	test.That(t, actual).IsLessThan(float64(time.Millisecond * 5))
+}
+
+func TestMeasuredResponseWriterShouldReturnStartTime(t *testing.T) {
+	// Arrange.
+	before := time.Now()
+	fixture := SetupMeasuredResponseWriterFixture()
+
+	// Act.
+	startTime := fixture.x.StartTime()
+
+	// Assert.
+	test.That(t, startTime.Before(before)).IsFalse()
+	test.That(t, float64(startTime.Sub(before))).IsLessThan(float64(time.Millisecond * 5))
+}
+
+func TestMeasuredResponseWriterResetBehavesLikeNew(t *testing.T) {
+	// Arrange.
+	fixture := SetupMeasuredResponseWriterFixture()
+	fixture.x.startTime = time.Now().Add(-time.Hour)
+	fixture.x.WriteHeader(http.StatusCreated)
+	fixture.x.Write([]byte("Hello, World!"))
+
+	// Act.
+	w := httptest.NewRecorder()
+	fixture.x.Reset(w)
+	fixture.x.WriteHeader(http.StatusAccepted)
+
+	// Assert.
+	test.That(t, fixture.x.StatusCode()).IsEqualTo(http.StatusAccepted)
+	test.That(t, fixture.x.Volume()).IsEqualTo(int64(0))
+	test.That(t, fixture.x.Duration()).IsEqualTo(time.Duration(0))
+	test.That(t, w.Result().StatusCode).IsEqualTo(http.StatusAccepted)
+}
+
+func TestMeasuredResponseWriterPoolDoesNotLeakState(t *testing.T) {
+	// Arrange.
+	mrw := AcquireMeasuredResponseWriter(httptest.NewRecorder())
+	mrw.WriteHeader(http.StatusCreated)
+	mrw.Write([]byte("Hello, World!"))
+	ReleaseMeasuredResponseWriter(mrw)
+
+	// Act.
+	w := httptest.NewRecorder()
+	mrw = AcquireMeasuredResponseWriter(w)
+
+	// Assert.
+	test.That(t, mrw.HasWrittenHeaders()).IsFalse()
+	test.That(t, mrw.StatusCode()).IsEqualTo(http.StatusOK)
+	test.That(t, mrw.Volume()).IsEqualTo(int64(0))
+
+	mrw.Write([]byte("Hi"))
+	test.That(t, w.Body.String()).IsEqualTo("Hi")
+	ReleaseMeasuredResponseWriter(mrw)
+}
+
+func TestMeasuredResponseWriterReadFrom(t *testing.T) {
+	// Arrange.
+	fixture := SetupMeasuredResponseWriterFixture()
+
+	// Act.
+	n, err := fixture.x.ReadFrom(strings.NewReader("Hello, World!"))
+
+	// Assert.
+	test.That(t, err).IsNil()
+	test.That(t, n).IsEqualTo(int64(13))
+	test.That(t, fixture.x.Volume()).IsEqualTo(int64(13))
+	test.That(t, fixture.x.StatusCode()).IsEqualTo(http.StatusOK)
+}
@@ -0,0 +1,102 @@
+package web
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryIdempotencyStore is an in-memory IdempotencyStore.  Stored responses
+// expire after the configured TTL.  It is thread-safe.
+type MemoryIdempotencyStore struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mx       sync.Mutex
+	inFlight map[string]struct{}
+	entries  map[string]*memoryIdempotencyEntry
+}
+
+type memoryIdempotencyEntry struct {
+	response  *IdempotentResponse
+	expiresAt time.Time
+}
+
+var _ IdempotencyStore = &MemoryIdempotencyStore{}
+
+// NewMemoryIdempotencyStore creates a new MemoryIdempotencyStore whose stored
+// responses expire after ttl.
+func NewMemoryIdempotencyStore(ttl time.Duration) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		ttl: ttl,
+		now: time.Now,
+
+		inFlight: make(map[string]struct{}),
+		entries:  make(map[string]*memoryIdempotencyEntry),
+	}
+}
+
+// Reserve marks the key as in-flight, returning false if the key is already
+// in-flight or has a stored response.
+func (s *MemoryIdempotencyStore) Reserve(key string) (bool, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.removeExpired()
+
+	_, isInFlight := s.inFlight[key]
+	_, hasEntry := s.entries[key]
+	if isInFlight || hasEntry {
+		return false, nil
+	}
+
+	s.inFlight[key] = struct{}{}
+	return true, nil
+}
+
+// Get returns the stored response for the key, or nil if there is none.
+func (s *MemoryIdempotencyStore) Get(key string) (*IdempotentResponse, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.removeExpired()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+
+	return entry.response, nil
+}
+
+// Save stores the response for the key and removes its reservation.
+func (s *MemoryIdempotencyStore) Save(key string, response *IdempotentResponse) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	delete(s.inFlight, key)
+	s.entries[key] = &memoryIdempotencyEntry{
+		response:  response,
+		expiresAt: s.now().Add(s.ttl),
+	}
+
+	return nil
+}
+
+// Release removes the reservation for the key.
+func (s *MemoryIdempotencyStore) Release(key string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	delete(s.inFlight, key)
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) removeExpired() {
+	now := s.now()
+
+	for key, entry := range s.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
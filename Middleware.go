@@ -2,7 +2,9 @@ package web
 
 // Middleware defines the methods that any HTTP middleware must implement.  If
 // the Handle method returns true, the request will continue to be propagated to
-// subsequent middleware handlers and eventually the route handler.
+// subsequent middleware handlers and eventually the route handler.  If it
+// returns false, the middleware must have already responded to the request -
+// otherwise, an InternalServerError response is sent on its behalf.
 type Middleware interface {
 	Handle(ctx *Context) bool
 }
@@ -0,0 +1,70 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// NDJSONWriter writes newline-delimited JSON records to a response.  It is
+// created with Context.StreamNDJSON.  The response is flushed every
+// StreamFlushInterval records, and can be flushed explicitly with Flush.  If
+// writing a record fails, the status code can no longer be changed, so the
+// error is logged to the logging.Logger registered in the container, or to the
+// logger of the HandlerBuilder if there is none.
+type NDJSONWriter struct {
+	ctx     *Context
+	enc     *json.Encoder
+	flusher http.Flusher
+	count   int
+	err     error
+}
+
+func newNDJSONWriter(ctx *Context) *NDJSONWriter {
+	flusher, _ := ctx.w.(http.Flusher)
+
+	return &NDJSONWriter{
+		ctx:     ctx,
+		enc:     json.NewEncoder(ctx.w),
+		flusher: flusher,
+	}
+}
+
+// Write encodes the provided value as a single line of JSON.  Once a Write has
+// failed, or the request has been cancelled, all further calls to Write return
+// an error without writing anything.
+func (nw *NDJSONWriter) Write(v interface{}) error {
+	if nw.err != nil {
+		return nw.err
+	}
+
+	if err := nw.ctx.r.Context().Err(); err != nil {
+		return nw.fail(err)
+	}
+
+	err := nw.enc.Encode(v)
+	if err != nil {
+		return nw.fail(err)
+	}
+
+	nw.count++
+	if nw.count%StreamFlushInterval == 0 {
+		nw.Flush()
+	}
+
+	return nil
+}
+
+// Flush sends any buffered records to the client, if the underlying
+// http.ResponseWriter supports it.
+func (nw *NDJSONWriter) Flush() {
+	if nw.flusher != nil {
+		nw.flusher.Flush()
+	}
+}
+
+func (nw *NDJSONWriter) fail(err error) error {
+	nw.err = fmt.Errorf("streaming NDJSON failed after %v records: %w", nw.count, err)
+	logStreamFailure(nw.ctx, nw.err)
+	return nw.err
+}
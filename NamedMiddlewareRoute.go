@@ -0,0 +1,11 @@
+package web
+
+// NamedMiddlewareRoute is an optional interface that a Route can implement to
+// reference middleware registered with HandlerBuilder.UseNamedMiddleware.  The
+// referenced middleware always run in the order in which they were registered
+// with the HandlerBuilder, regardless of the order in which they are listed,
+// and run before the middleware returned by Middleware.
+type NamedMiddlewareRoute interface {
+	Route
+	NamedMiddleware() []string
+}
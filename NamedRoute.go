@@ -0,0 +1,9 @@
+package web
+
+// NamedRoute is an optional interface that a Route can implement to give itself
+// a unique name.  Named routes can have URLs generated for them using
+// HandlerBuilder.URLFor.
+type NamedRoute interface {
+	Route
+	Name() string
+}
@@ -0,0 +1,10 @@
+package web
+
+// Page is the envelope used by Context.RespondWithPage to respond with a single
+// page of a paginated collection.  Pages are numbered from 1.
+type Page struct {
+	Data     interface{} `json:"data"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"pageSize"`
+	Total    int         `json:"total"`
+}
@@ -0,0 +1,115 @@
+package web
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimitMiddleware is a Middleware that limits the rate at which requests
+// can be made by each client IP address using a token bucket.  Each client may
+// make up to burst requests at once, after which requests are permitted at the
+// configured rate.  RateLimitMiddleware is thread-safe, and a single instance
+// should be shared across all requests for the routes it protects.
+type RateLimitMiddleware struct {
+	rate  float64
+	burst float64
+
+	mx        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+var _ Middleware = &RateLimitMiddleware{}
+
+// NewRateLimitMiddleware creates a new RateLimitMiddleware that permits rate
+// requests per second per client IP address, with bursts of up to burst
+// requests.  Both rate and burst must be positive, or NewRateLimitMiddleware
+// will panic.
+func NewRateLimitMiddleware(rate float64, burst int) *RateLimitMiddleware {
+	if rate <= 0 || math.IsNaN(rate) {
+		panic(fmt.Sprintf("the rate of a RateLimitMiddleware must be positive, but was %v", rate))
+	}
+
+	if burst <= 0 {
+		panic(fmt.Sprintf("the burst of a RateLimitMiddleware must be positive, but was %v", burst))
+	}
+
+	return &RateLimitMiddleware{
+		rate:  rate,
+		burst: float64(burst),
+
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Handle takes a token from the bucket for the client IP address of the
+// request.  If the bucket is empty, a TooManyRequests response is sent and
+// false is returned.
+func (m *RateLimitMiddleware) Handle(ctx *Context) bool {
+	allowed, retryAfter := m.take(ctx.ClientIP())
+	if !allowed {
+		ctx.TooManyRequests(retryAfter)
+		return false
+	}
+
+	return true
+}
+
+func (m *RateLimitMiddleware) take(key string) (bool, time.Duration) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	bucket, ok := m.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: m.burst, lastRefill: now}
+		m.buckets[key] = bucket
+	}
+
+	m.refill(bucket, now)
+
+	if bucket.tokens < 1 {
+		wait := (1 - bucket.tokens) / m.rate
+		return false, time.Duration(wait * float64(time.Second))
+	}
+
+	bucket.tokens--
+	return true, 0
+}
+
+func (m *RateLimitMiddleware) refill(bucket *tokenBucket, now time.Time) {
+	elapsed := now.Sub(bucket.lastRefill).Seconds()
+	bucket.tokens = math.Min(m.burst, bucket.tokens+elapsed*m.rate)
+	bucket.lastRefill = now
+}
+
+// sweep removes any buckets that have refilled completely, as they are
+// indistinguishable from a newly created bucket.  Sweeps happen at most once
+// per the time it takes for an empty bucket to refill.
+func (m *RateLimitMiddleware) sweep(now time.Time) {
+	refillDuration := time.Duration(m.burst / m.rate * float64(time.Second))
+	if now.Sub(m.lastSweep) < refillDuration {
+		return
+	}
+
+	for key, bucket := range m.buckets {
+		m.refill(bucket, now)
+		if bucket.tokens >= m.burst {
+			delete(m.buckets, key)
+		}
+	}
+
+	m.lastSweep = now
+}
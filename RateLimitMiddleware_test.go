@@ -0,0 +1,137 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ljpx/di"
+	"github.com/ljpx/test"
+)
+
+type RateLimitMiddlewareFixture struct {
+	x   *RateLimitMiddleware
+	now time.Time
+}
+
+func SetupRateLimitMiddlewareFixture() *RateLimitMiddlewareFixture {
+	fixture := &RateLimitMiddlewareFixture{}
+	fixture.now = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	fixture.x = NewRateLimitMiddleware(1, 3)
+	fixture.x.lastSweep = fixture.now
+	fixture.x.now = func() time.Time {
+		return fixture.now
+	}
+
+	return fixture
+}
+
+func (fixture *RateLimitMiddlewareFixture) handle(remoteAddr string) (bool, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = remoteAddr
+
+	ctx := NewContext(w, r, di.NewContainer(), &Config{
+		ProblemDetailsTypePrefix: "https://testi.ng",
+	})
+
+	return fixture.x.Handle(ctx), w
+}
+
+func TestRateLimitMiddlewareAllowsBurstThenRejects(t *testing.T) {
+	// Arrange.
+	fixture := SetupRateLimitMiddlewareFixture()
+
+	// Act.
+	for i := 0; i < 3; i++ {
+		allowed, _ := fixture.handle("192.0.2.1:1234")
+		test.That(t, allowed).IsTrue()
+	}
+
+	allowed, w := fixture.handle("192.0.2.1:1234")
+
+	// Assert.
+	test.That(t, allowed).IsFalse()
+
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusTooManyRequests)
+	test.That(t, res.Header.Get("Retry-After")).IsEqualTo("1")
+}
+
+func TestRateLimitMiddlewareTracksClientsSeparately(t *testing.T) {
+	// Arrange.
+	fixture := SetupRateLimitMiddlewareFixture()
+
+	for i := 0; i < 3; i++ {
+		fixture.handle("192.0.2.1:1234")
+	}
+
+	// Act.
+	allowed, _ := fixture.handle("192.0.2.2:1234")
+
+	// Assert.
+	test.That(t, allowed).IsTrue()
+}
+
+func TestRateLimitMiddlewareRefillsOverTime(t *testing.T) {
+	// Arrange.
+	fixture := SetupRateLimitMiddlewareFixture()
+
+	for i := 0; i < 3; i++ {
+		fixture.handle("192.0.2.1:1234")
+	}
+
+	// Act.
+	fixture.now = fixture.now.Add(time.Second)
+	allowedAfterRefill, _ := fixture.handle("192.0.2.1:1234")
+	allowedAfterExhaustion, _ := fixture.handle("192.0.2.1:1234")
+
+	// Assert.
+	test.That(t, allowedAfterRefill).IsTrue()
+	test.That(t, allowedAfterExhaustion).IsFalse()
+}
+
+func TestRateLimitMiddlewareRemovesStaleBuckets(t *testing.T) {
+	// Arrange.
+	fixture := SetupRateLimitMiddlewareFixture()
+	fixture.handle("192.0.2.1:1234")
+	fixture.handle("192.0.2.2:1234")
+
+	// Act.
+	fixture.now = fixture.now.Add(time.Second * 3)
+	fixture.handle("192.0.2.3:1234")
+
+	// Assert.
+	test.That(t, len(fixture.x.buckets)).IsEqualTo(1)
+}
+
+func TestNewRateLimitMiddlewareRejectsInvalidArguments(t *testing.T) {
+	testCases := []struct {
+		rate     float64
+		burst    int
+		expected string
+	}{
+		{rate: 0, burst: 3, expected: "the rate of a RateLimitMiddleware must be positive, but was 0"},
+		{rate: -1, burst: 3, expected: "the rate of a RateLimitMiddleware must be positive, but was -1"},
+		{rate: 1, burst: 0, expected: "the burst of a RateLimitMiddleware must be positive, but was 0"},
+	}
+
+	for _, testCase := range testCases {
+		// Arrange.
+		var recovered interface{}
+
+		// Act.
+		func() {
+			defer func() {
+				recovered = recover()
+			}()
+
+			NewRateLimitMiddleware(testCase.rate, testCase.burst)
+		}()
+
+		// Assert.
+		test.That(t, recovered).IsEqualTo(interface{}(testCase.expected))
+	}
+}
@@ -0,0 +1,23 @@
+package web
+
+// RequireHeadersMiddleware is a Middleware that ensures that a set of request
+// headers are present and non-empty.  If any are missing, a BadRequest response
+// naming the missing headers is sent.
+type RequireHeadersMiddleware struct {
+	names []string
+}
+
+var _ Middleware = &RequireHeadersMiddleware{}
+
+// NewRequireHeadersMiddleware creates a new RequireHeadersMiddleware that
+// requires the provided headers.
+func NewRequireHeadersMiddleware(names ...string) *RequireHeadersMiddleware {
+	return &RequireHeadersMiddleware{
+		names: names,
+	}
+}
+
+// Handle asserts that the required headers are present in the request.
+func (m *RequireHeadersMiddleware) Handle(ctx *Context) bool {
+	return ctx.AssertHeaders(m.names...)
+}
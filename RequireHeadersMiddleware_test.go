@@ -0,0 +1,72 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ljpx/di"
+	"github.com/ljpx/test"
+)
+
+type RequireHeadersMiddlewareFixture struct {
+	x *RequireHeadersMiddleware
+}
+
+func SetupRequireHeadersMiddlewareFixture() *RequireHeadersMiddlewareFixture {
+	fixture := &RequireHeadersMiddlewareFixture{}
+	fixture.x = NewRequireHeadersMiddleware("X-Api-Version", "X-Tenant")
+
+	return fixture
+}
+
+func (fixture *RequireHeadersMiddlewareFixture) handle(header http.Header) (bool, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header = header
+
+	ctx := NewContext(w, r, di.NewContainer(), &Config{
+		ProblemDetailsTypePrefix: "https://testi.ng",
+	})
+
+	return fixture.x.Handle(ctx), w
+}
+
+func TestRequireHeadersMiddlewarePresent(t *testing.T) {
+	// Arrange.
+	fixture := SetupRequireHeadersMiddlewareFixture()
+	header := http.Header{}
+	header.Set("X-Api-Version", "2")
+	header.Set("X-Tenant", "acme")
+
+	// Act.
+	passed, w := fixture.handle(header)
+
+	// Assert.
+	test.That(t, passed).IsTrue()
+	test.That(t, w.Body.Len()).IsEqualTo(0)
+}
+
+func TestRequireHeadersMiddlewareMissing(t *testing.T) {
+	// Arrange.
+	fixture := SetupRequireHeadersMiddlewareFixture()
+	header := http.Header{}
+	header.Set("X-Tenant", " ")
+
+	// Act.
+	passed, w := fixture.handle(header)
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	res := w.Result()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusBadRequest)
+
+	rawJSON, err := ioutil.ReadAll(res.Body)
+	test.That(t, err).IsNil()
+
+	json := string(rawJSON)
+	expectedJSON := `{"type":"https://testi.ng/http/missing-headers","title":"Missing Headers","detail":"This endpoint requires the headers 'X-Api-Version', 'X-Tenant'.","specifics":{"missingHeaders":["X-Api-Version","X-Tenant"]}}`
+	test.That(t, json).IsEqualTo(expectedJSON)
+}
@@ -0,0 +1,9 @@
+package web
+
+// SchemaValidatable is an optional interface that a request model passed to
+// FromJSON can implement to have the raw request body validated against a JSON
+// Schema before it is deserialized.  Validation is performed by
+// Config.JSONSchemaValidator.
+type SchemaValidatable interface {
+	Schema() []byte
+}
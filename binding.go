@@ -0,0 +1,166 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var errUnsupportedBindingType = errors.New("unsupported binding type")
+
+// fieldBindingError describes a value that could not be converted to the type
+// of the struct field it was being bound to.
+type fieldBindingError struct {
+	name     string
+	value    string
+	typeName string
+	err      error
+}
+
+func (e *fieldBindingError) Error() string {
+	return fmt.Sprintf("the value '%v' for '%v' could not be converted to %v", e.value, e.name, e.typeName)
+}
+
+// bindStruct populates the fields of the struct pointed to by model.  For each
+// field, the name is taken from the first of the provided tag keys that is
+// present on the field, and lookup is used to retrieve the values for that
+// name.  Fields without any of the tags, or with a tag of "-", are skipped.
+func bindStruct(model interface{}, tagKeys []string, lookup func(name string) []string) error {
+	modelValue := reflect.ValueOf(model)
+	if modelValue.Kind() != reflect.Ptr || modelValue.IsNil() || modelValue.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("the model must be a non-nil pointer to a struct, not %T", model)
+	}
+
+	structValue := modelValue.Elem()
+	structType := structValue.Type()
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := fieldBindingName(field, tagKeys)
+		if name == "" {
+			continue
+		}
+
+		values := lookup(name)
+		if len(values) == 0 {
+			continue
+		}
+
+		err := bindField(structValue.Field(i), values)
+		if err == errUnsupportedBindingType {
+			return fmt.Errorf("the field '%v' has the unsupported type %v", field.Name, field.Type)
+		} else if err != nil {
+			return &fieldBindingError{
+				name:     name,
+				value:    strings.Join(values, ","),
+				typeName: scalarTypeName(field.Type),
+				err:      err,
+			}
+		}
+	}
+
+	return nil
+}
+
+func fieldBindingName(field reflect.StructField, tagKeys []string) string {
+	for _, tagKey := range tagKeys {
+		tag, ok := field.Tag.Lookup(tagKey)
+		if !ok {
+			continue
+		}
+
+		name := strings.TrimSpace(strings.Split(tag, ",")[0])
+		if name == "-" {
+			return ""
+		}
+
+		return name
+	}
+
+	return ""
+}
+
+func scalarTypeName(t reflect.Type) string {
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t.String()
+}
+
+func bindField(fieldValue reflect.Value, values []string) error {
+	switch fieldValue.Kind() {
+	case reflect.Slice:
+		slice := reflect.MakeSlice(fieldValue.Type(), len(values), len(values))
+		for i, value := range values {
+			err := bindScalar(slice.Index(i), value)
+			if err != nil {
+				return err
+			}
+		}
+
+		fieldValue.Set(slice)
+		return nil
+	case reflect.Ptr:
+		ptr := reflect.New(fieldValue.Type().Elem())
+		err := bindScalar(ptr.Elem(), values[0])
+		if err != nil {
+			return err
+		}
+
+		fieldValue.Set(ptr)
+		return nil
+	default:
+		return bindScalar(fieldValue, values[0])
+	}
+}
+
+func bindScalar(v reflect.Value, raw string) error {
+	if v.Kind() == reflect.String {
+		v.SetString(raw)
+		return nil
+	}
+
+	raw = strings.TrimSpace(raw)
+
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetFloat(f)
+	default:
+		return errUnsupportedBindingType
+	}
+
+	return nil
+}
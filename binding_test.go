@@ -0,0 +1,38 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/ljpx/test"
+)
+
+func TestBindStructRequiresStructPointer(t *testing.T) {
+	// Arrange.
+	model := struct{ Name string }{}
+
+	// Act.
+	err := bindStruct(model, []string{"query"}, func(name string) []string {
+		return []string{"value"}
+	})
+
+	// Assert.
+	test.That(t, err).IsNotNil()
+	test.That(t, err.Error()).IsEqualTo("the model must be a non-nil pointer to a struct, not struct { Name string }")
+}
+
+func TestBindStructUnsupportedType(t *testing.T) {
+	// Arrange.
+	model := &struct {
+		Values map[string]string `query:"values"`
+	}{}
+
+	// Act.
+	err := bindStruct(model, []string{"query"}, func(name string) []string {
+		return []string{"value"}
+	})
+
+	// Assert.
+	_, isFieldBindingError := err.(*fieldBindingError)
+	test.That(t, isFieldBindingError).IsFalse()
+	test.That(t, err.Error()).IsEqualTo("the field 'Values' has the unsupported type map[string]string")
+}
@@ -1,6 +1,6 @@
 module github.com/ljpx/web
 
-go 1.13
+go 1.18
 
 require (
 	github.com/gorilla/mux v1.7.3
@@ -0,0 +1,221 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeReflectionType = reflect.TypeOf(time.Time{})
+
+// openAPIOperation builds the OpenAPI operation object for the provided route.
+func openAPIOperation(route Route, parameters []string) map[string]interface{} {
+	operation := map[string]interface{}{}
+
+	if namedRoute, ok := routeExtension(route).(interface{ Name() string }); ok {
+		operation["operationId"] = namedRoute.Name()
+	}
+
+	if len(parameters) > 0 {
+		pathParameters := []interface{}{}
+		for _, parameter := range parameters {
+			pathParameters = append(pathParameters, map[string]interface{}{
+				"name":     parameter,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+
+		operation["parameters"] = pathParameters
+	}
+
+	responses := map[string]interface{}{
+		"default": map[string]interface{}{
+			"description": "A problem describing why the request failed.",
+			"content": map[string]interface{}{
+				"application/problem+json": map[string]interface{}{
+					"schema": map[string]interface{}{"type": "object"},
+				},
+			},
+		},
+	}
+
+	operation["responses"] = responses
+
+	documentedRoute, ok := routeExtension(route).(interface {
+		Summary() string
+		RequestModel() interface{}
+		ResponseModel() interface{}
+	})
+	if !ok {
+		return operation
+	}
+
+	if summary := documentedRoute.Summary(); summary != "" {
+		operation["summary"] = summary
+	}
+
+	if requestModel := documentedRoute.RequestModel(); requestModel != nil {
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  openAPIJSONContent(requestModel),
+		}
+	}
+
+	if responseModel := documentedRoute.ResponseModel(); responseModel != nil {
+		responses["200"] = map[string]interface{}{
+			"description": http.StatusText(http.StatusOK),
+			"content":     openAPIJSONContent(responseModel),
+		}
+	}
+
+	return operation
+}
+
+func openAPIJSONContent(model interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{
+			"schema": jsonSchemaFor(reflect.TypeOf(model), make(map[reflect.Type]bool)),
+		},
+	}
+}
+
+// openAPIPath converts the provided mux path template to an OpenAPI path
+// template by removing the patterns of variables, and returns the names of the
+// variables in the order in which they appear.
+func openAPIPath(path string) (string, []string) {
+	var sb strings.Builder
+	var name strings.Builder
+	parameters := []string{}
+	depth := 0
+	inPattern := false
+
+	for _, r := range path {
+		switch {
+		case r == '{':
+			depth++
+			if depth == 1 {
+				name.Reset()
+				inPattern = false
+				sb.WriteRune(r)
+				continue
+			}
+		case r == '}' && depth > 0:
+			depth--
+			if depth == 0 {
+				parameters = append(parameters, name.String())
+				sb.WriteString(name.String())
+				sb.WriteRune(r)
+				continue
+			}
+		case r == ':' && depth == 1:
+			inPattern = true
+			continue
+		}
+
+		if depth == 0 {
+			sb.WriteRune(r)
+		} else if !inPattern {
+			name.WriteRune(r)
+		}
+	}
+
+	return sb.String(), parameters
+}
+
+// jsonSchemaFor derives a JSON schema for values of the provided type, as they
+// would be encoded by encoding/json.  Types that are already being described
+// further up the tree are described as plain objects, so that recursive types
+// terminate.
+func jsonSchemaFor(t reflect.Type, visiting map[reflect.Type]bool) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timeReflectionType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+
+		return map[string]interface{}{"type": "array", "items": jsonSchemaFor(t.Elem(), visiting)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchemaFor(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		properties := map[string]interface{}{}
+		addJSONSchemaProperties(t, properties, visiting)
+
+		return map[string]interface{}{"type": "object", "properties": properties}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func addJSONSchemaProperties(t reflect.Type, properties map[string]interface{}, visiting map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			tagName := strings.Split(tag, ",")[0]
+			if tagName == "-" {
+				continue
+			}
+
+			if tagName != "" {
+				name = tagName
+			}
+		}
+
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+
+			if fieldType.Kind() == reflect.Struct {
+				addJSONSchemaProperties(fieldType, properties, visiting)
+				continue
+			}
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		properties[name] = jsonSchemaFor(field.Type, visiting)
+	}
+}
+
+// describeHost describes the host of a HandlerBuilder for use in error
+// messages.  The root builder has no host, and serves requests for any host.
+func describeHost(host string) string {
+	if host == "" {
+		return "any host"
+	}
+
+	return fmt.Sprintf("host %v", host)
+}
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// invalidField describes a field that failed purification.
+type invalidField struct {
+	field string
+	err   error
+}
+
+// purify invokes Purify on the provided model if it is Purifiable, and then on
+// any Purifiable values nested within its exported struct fields and the
+// elements of its slices and arrays.  Every failure is returned, and the name of
+// each invalid field is prefixed with its path from the model, using the JSON
+// names of the fields, such as "address.postcode" or "items[2].sku".
+func purify(model interface{}) []invalidField {
+	return purifyValue(reflect.ValueOf(model), "", nil)
+}
+
+func purifyValue(v reflect.Value, path string, invalidFields []invalidField) []invalidField {
+	if !v.IsValid() {
+		return invalidFields
+	}
+
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return invalidFields
+	}
+
+	if purifiable, ok := asPurifiable(v); ok {
+		field, err := purifiable.Purify()
+		if err != nil {
+			invalidFields = append(invalidFields, invalidField{field: joinFieldPath(path, field), err: err})
+		}
+	}
+
+	return purifyChildren(v, path, invalidFields)
+}
+
+func purifyChildren(v reflect.Value, path string, invalidFields []invalidField) []invalidField {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return invalidFields
+		}
+
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			invalidFields = purifyValue(v.Index(i), fmt.Sprintf("%v[%v]", path, i), invalidFields)
+		}
+	case reflect.Struct:
+		structType := v.Type()
+		for i := 0; i < structType.NumField(); i++ {
+			field := structType.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+
+			name := jsonFieldName(field)
+			if name == "" {
+				continue
+			}
+
+			// The Purify method of an embedded struct is promoted to the struct
+			// embedding it, so only the fields of an embedded struct are purified.
+			if field.Anonymous {
+				invalidFields = purifyChildren(v.Field(i), path, invalidFields)
+			} else {
+				invalidFields = purifyValue(v.Field(i), joinFieldPath(path, name), invalidFields)
+			}
+		}
+	}
+
+	return invalidFields
+}
+
+func asPurifiable(v reflect.Value) (Purifiable, bool) {
+	if v.Kind() != reflect.Ptr && v.CanAddr() {
+		v = v.Addr()
+	}
+
+	if !v.CanInterface() {
+		return nil, false
+	}
+
+	purifiable, ok := v.Interface().(Purifiable)
+	return purifiable, ok
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
+
+func joinFieldPath(path string, field string) string {
+	if path == "" {
+		return field
+	}
+
+	if field == "" {
+		return path
+	}
+
+	return path + "." + field
+}
@@ -0,0 +1,134 @@
+package web
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ljpx/test"
+)
+
+func TestPurifyNestedStruct(t *testing.T) {
+	// Arrange.
+	model := &testPurifyOrder{
+		Reference: "abc",
+		Address:   testPurifyAddress{Postcode: "invalid"},
+	}
+
+	// Act.
+	invalidFields := purify(model)
+
+	// Assert.
+	test.That(t, len(invalidFields)).IsEqualTo(1)
+	test.That(t, invalidFields[0].field).IsEqualTo("address.postcode")
+}
+
+func TestPurifyNestedPointer(t *testing.T) {
+	// Arrange.
+	model := &testPurifyOrder{
+		Reference: "abc",
+		Billing:   &testPurifyAddress{Postcode: "invalid"},
+	}
+
+	// Act.
+	invalidFields := purify(model)
+
+	// Assert.
+	test.That(t, len(invalidFields)).IsEqualTo(1)
+	test.That(t, invalidFields[0].field).IsEqualTo("billing.postcode")
+}
+
+func TestPurifyAggregatesFailures(t *testing.T) {
+	// Arrange.
+	model := &testPurifyOrder{
+		Address: testPurifyAddress{Postcode: "invalid"},
+	}
+
+	// Act.
+	invalidFields := purify(model)
+
+	// Assert.
+	test.That(t, len(invalidFields)).IsEqualTo(2)
+	test.That(t, invalidFields[0].field).IsEqualTo("reference")
+	test.That(t, invalidFields[1].field).IsEqualTo("address.postcode")
+}
+
+func TestPurifySliceElements(t *testing.T) {
+	// Arrange.
+	model := &testPurifyOrder{
+		Reference: "abc",
+		Items: []*testPurifyItem{
+			{SKU: "a"},
+			{SKU: "b"},
+			{SKU: ""},
+		},
+	}
+
+	// Act.
+	invalidFields := purify(model)
+
+	// Assert.
+	test.That(t, len(invalidFields)).IsEqualTo(1)
+	test.That(t, invalidFields[0].field).IsEqualTo("items[2].sku")
+	test.That(t, invalidFields[0].err.Error()).IsEqualTo("is required")
+}
+
+func TestPurifyValid(t *testing.T) {
+	// Arrange.
+	model := &testPurifyOrder{
+		Reference: "abc",
+		Address:   testPurifyAddress{Postcode: "2000"},
+	}
+
+	// Act.
+	invalidFields := purify(model)
+
+	// Assert.
+	test.That(t, len(invalidFields)).IsEqualTo(0)
+}
+
+// -----------------------------------------------------------------------------
+
+type testPurifyOrder struct {
+	Reference string             `json:"reference"`
+	Address   testPurifyAddress  `json:"address"`
+	Billing   *testPurifyAddress `json:"billing,omitempty"`
+	Items     []*testPurifyItem  `json:"items"`
+}
+
+var _ Purifiable = &testPurifyOrder{}
+
+func (m *testPurifyOrder) Purify() (string, error) {
+	if m.Reference == "" {
+		return "reference", fmt.Errorf("is required")
+	}
+
+	return "", nil
+}
+
+type testPurifyAddress struct {
+	Postcode string `json:"postcode"`
+}
+
+var _ Purifiable = &testPurifyAddress{}
+
+func (m *testPurifyAddress) Purify() (string, error) {
+	if m.Postcode == "invalid" {
+		return "postcode", fmt.Errorf("cannot be the string 'invalid'")
+	}
+
+	return "", nil
+}
+
+type testPurifyItem struct {
+	SKU string `json:"sku"`
+}
+
+var _ Purifiable = &testPurifyItem{}
+
+func (m *testPurifyItem) Purify() (string, error) {
+	if m.SKU == "" {
+		return "sku", fmt.Errorf("is required")
+	}
+
+	return "", nil
+}
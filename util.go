@@ -1,10 +1,22 @@
 package web
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
+	"mime"
+	"net"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ljpx/problem"
 )
 
 // ByteSizeToFriendlyString returns the provided byte length as a human-friendly
@@ -32,3 +44,529 @@ func UnmarshalFromResponse(res *http.Response, model interface{}) error {
 
 	return json.Unmarshal(raw, model)
 }
+
+// IsProblemResponse returns true if the provided http.Response has an error
+// status code and a Content-Type of application/problem+json or
+// application/json.
+func IsProblemResponse(res *http.Response) bool {
+	return res.StatusCode >= http.StatusBadRequest && isProblemMediaType(mediaType(res.Header.Get("Content-Type")))
+}
+
+// DecodeProblem unmarshals the body of an http.Response to problem details.  An
+// error is returned if the Content-Type of the response is not
+// application/problem+json or application/json.
+func DecodeProblem(res *http.Response) (*problem.Details, error) {
+	contentType := res.Header.Get("Content-Type")
+	if !isProblemMediaType(mediaType(contentType)) {
+		return nil, fmt.Errorf("the response has the Content-Type '%v', which cannot contain problem details", contentType)
+	}
+
+	details := &problem.Details{}
+	err := UnmarshalFromResponse(res, details)
+	if err != nil {
+		return nil, err
+	}
+
+	return details, nil
+}
+
+func isProblemMediaType(mediaType string) bool {
+	mediaType = strings.ToLower(mediaType)
+	return mediaType == "application/problem+json" || mediaType == "application/json"
+}
+
+// applyMergePatch applies the provided JSON Merge Patch (RFC 7396) to the value
+// pointed to by target.  The patch is merged into the existing value, so only
+// the members named by the patch are changed, and fields that are not
+// represented in JSON, such as unexported fields and fields tagged json:"-",
+// are left untouched.
+func applyMergePatch(target interface{}, patch map[string]interface{}) error {
+	targetValue := reflect.ValueOf(target)
+	if targetValue.Kind() != reflect.Ptr || targetValue.IsNil() {
+		return fmt.Errorf("the merge patch target must be a non-nil pointer, not %T", target)
+	}
+
+	return mergePatchInto(targetValue.Elem(), patch)
+}
+
+// mergePatchInto merges patch into the settable value target.  Objects are
+// merged into structs and maps member by member, with null members clearing the
+// corresponding field or deleting the corresponding key, and all other values
+// replace target entirely.
+func mergePatchInto(target reflect.Value, patch interface{}) error {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return replaceWithJSON(target, patch)
+	}
+
+	for target.Kind() == reflect.Ptr {
+		if target.IsNil() {
+			target.Set(reflect.New(target.Type().Elem()))
+		}
+
+		target = target.Elem()
+	}
+
+	if _, ok := target.Addr().Interface().(json.Unmarshaler); ok {
+		return mergeJSONInto(target, patchObject)
+	}
+
+	switch target.Kind() {
+	case reflect.Struct:
+		for key, value := range patchObject {
+			field, ok := jsonField(target, key)
+			if !ok {
+				continue
+			}
+
+			if value == nil {
+				field.Set(reflect.Zero(field.Type()))
+				continue
+			}
+
+			err := mergePatchInto(field, value)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case reflect.Map:
+		if target.Type().Key().Kind() != reflect.String {
+			return mergeJSONInto(target, patchObject)
+		}
+
+		if target.IsNil() {
+			target.Set(reflect.MakeMap(target.Type()))
+		}
+
+		for key, value := range patchObject {
+			mapKey := reflect.ValueOf(key).Convert(target.Type().Key())
+			if value == nil {
+				target.SetMapIndex(mapKey, reflect.Value{})
+				continue
+			}
+
+			elem := reflect.New(target.Type().Elem()).Elem()
+			if existing := target.MapIndex(mapKey); existing.IsValid() {
+				elem.Set(existing)
+			}
+
+			err := mergePatchInto(elem, value)
+			if err != nil {
+				return err
+			}
+
+			target.SetMapIndex(mapKey, elem)
+		}
+
+		return nil
+	}
+
+	return mergeJSONInto(target, patchObject)
+}
+
+// mergeJSONInto merges patch into target by way of its JSON representation.  It
+// is used for values that cannot be merged field by field, such as interfaces
+// and types with custom JSON encodings.
+func mergeJSONInto(target reflect.Value, patch map[string]interface{}) error {
+	rawTarget, err := json.Marshal(target.Addr().Interface())
+	if err != nil {
+		return err
+	}
+
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewReader(rawTarget))
+	decoder.UseNumber()
+	err = decoder.Decode(&document)
+	if err != nil {
+		return err
+	}
+
+	return replaceWithJSON(target, mergePatch(document, patch))
+}
+
+// replaceWithJSON replaces target with the result of decoding the JSON
+// representation of value into a new value of the same type.
+func replaceWithJSON(target reflect.Value, value interface{}) error {
+	rawValue, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	replacement := reflect.New(target.Type())
+	err = json.Unmarshal(rawValue, replacement.Interface())
+	if err != nil {
+		return err
+	}
+
+	target.Set(replacement.Elem())
+	return nil
+}
+
+// jsonField returns the field of the struct target that encoding/json would
+// decode the object member name into, allocating any nil embedded structs on
+// the way.  Exact matches are preferred over case-insensitive ones.
+func jsonField(target reflect.Value, name string) (reflect.Value, bool) {
+	index := jsonFieldIndex(target.Type(), func(fieldName string) bool {
+		return fieldName == name
+	})
+
+	if index == nil {
+		index = jsonFieldIndex(target.Type(), func(fieldName string) bool {
+			return strings.EqualFold(fieldName, name)
+		})
+	}
+
+	if index == nil {
+		return reflect.Value{}, false
+	}
+
+	field := target
+	for i, fieldIndex := range index {
+		for i > 0 && field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				if !field.CanSet() {
+					return reflect.Value{}, false
+				}
+
+				field.Set(reflect.New(field.Type().Elem()))
+			}
+
+			field = field.Elem()
+		}
+
+		field = field.Field(fieldIndex)
+	}
+
+	return field, field.CanSet()
+}
+
+func jsonFieldIndex(t reflect.Type, matches func(fieldName string) bool) []int {
+	var embedded []int
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tagName := strings.Split(field.Tag.Get("json"), ",")[0]
+		if tagName == "-" {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		if field.Anonymous && tagName == "" && fieldType.Kind() == reflect.Struct {
+			embedded = append(embedded, i)
+			continue
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		fieldName := field.Name
+		if tagName != "" {
+			fieldName = tagName
+		}
+
+		if matches(fieldName) {
+			return []int{i}
+		}
+	}
+
+	for _, i := range embedded {
+		fieldType := t.Field(i).Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		if index := jsonFieldIndex(fieldType, matches); index != nil {
+			return append([]int{i}, index...)
+		}
+	}
+
+	return nil
+}
+
+func mergePatch(document interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	documentObject, ok := document.(map[string]interface{})
+	if !ok {
+		documentObject = make(map[string]interface{})
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(documentObject, key)
+			continue
+		}
+
+		documentObject[key] = mergePatch(documentObject[key], value)
+	}
+
+	return documentObject
+}
+
+// jsonDepthExceeds returns true if the arrays and objects in the provided JSON
+// are nested more than maxDepth levels deep.  It does not validate the JSON.
+func jsonDepthExceeds(rawJSON []byte, maxDepth int) bool {
+	depth := 0
+	inString := false
+	escaped := false
+
+	for _, b := range rawJSON {
+		if inString {
+			if escaped {
+				escaped = false
+			} else if b == '\\' {
+				escaped = true
+			} else if b == '"' {
+				inString = false
+			}
+
+			continue
+		}
+
+		switch b {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > maxDepth {
+				return true
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+
+	return false
+}
+
+func isTrustedProxy(ip net.IP, trustedProxies []string) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, trustedProxy := range trustedProxies {
+		if strings.Contains(trustedProxy, "/") {
+			_, network, err := net.ParseCIDR(trustedProxy)
+			if err == nil && network.Contains(ip) {
+				return true
+			}
+
+			continue
+		}
+
+		if ip.Equal(net.ParseIP(trustedProxy)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func remoteAddrHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+
+	return host
+}
+
+func firstHeaderValue(header http.Header, name string) string {
+	value := header.Get(name)
+	if i := strings.Index(value, ","); i >= 0 {
+		value = value[:i]
+	}
+
+	return strings.TrimSpace(value)
+}
+
+// mediaType returns the provided Content-Type without any parameters, such as
+// charset.
+func mediaType(contentType string) string {
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+
+	return strings.TrimSpace(contentType)
+}
+
+// isJSONMediaType returns true if the provided media type is application/json
+// or has the +json structured syntax suffix defined by RFC 6839.
+func isJSONMediaType(mediaType string) bool {
+	mediaType = strings.ToLower(mediaType)
+	return mediaType == "application/json" || (strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"))
+}
+
+// contentDisposition formats a Content-Disposition header with the provided
+// disposition type and filename.  As described by RFC 6266, the filename
+// parameter contains an ASCII fallback of the filename, and the filename*
+// parameter contains the UTF-8 filename if it is not printable ASCII.
+func contentDisposition(dispositionType string, filename string) string {
+	fallback := strings.Builder{}
+	isASCII := true
+
+	for _, r := range filename {
+		switch {
+		case r < 0x20 || r > 0x7e:
+			fallback.WriteRune('_')
+			isASCII = false
+		case r == '"' || r == '\\':
+			fallback.WriteRune('\\')
+			fallback.WriteRune(r)
+		default:
+			fallback.WriteRune(r)
+		}
+	}
+
+	header := fmt.Sprintf(`%v; filename="%v"`, dispositionType, fallback.String())
+	if isASCII {
+		return header
+	}
+
+	encoded := strings.Builder{}
+	for _, b := range []byte(filename) {
+		if isRFC5987AttrChar(b) {
+			encoded.WriteByte(b)
+		} else {
+			encoded.WriteString(fmt.Sprintf("%%%02X", b))
+		}
+	}
+
+	return fmt.Sprintf("%v; filename*=UTF-8''%v", header, encoded.String())
+}
+
+func isRFC5987AttrChar(b byte) bool {
+	switch {
+	case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9':
+		return true
+	}
+
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
+
+// countingReader counts the number of bytes read from the underlying reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+
+	return n, err
+}
+
+// quoteETag surrounds the provided ETag with quotes if it is not already quoted.
+func quoteETag(etag string) string {
+	etag = strings.TrimSpace(etag)
+	if strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+
+	return fmt.Sprintf(`"%v"`, etag)
+}
+
+// etagListMatches returns true if the provided comma-separated list of ETags
+// contains the provided ETag, or is "*", using strong comparison.  Weak ETags
+// never match.
+func etagListMatches(list string, etag string) bool {
+	if strings.HasPrefix(etag, "W/") {
+		return false
+	}
+
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// describeJSONError adds the byte offset, and for type errors the field and
+// expected type, to the messages of errors returned when decoding JSON.  Other
+// errors are returned unchanged.
+func describeJSONError(err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return fmt.Errorf("%v at byte offset %v", syntaxErr, syntaxErr.Offset)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		if typeErr.Field == "" {
+			return fmt.Errorf("expected %v but found %v at byte offset %v", typeErr.Type, typeErr.Value, typeErr.Offset)
+		}
+
+		return fmt.Errorf("the field '%v' expected %v but found %v at byte offset %v", typeErr.Field, typeErr.Type, typeErr.Value, typeErr.Offset)
+	}
+
+	return err
+}
+
+// prefersHTML returns true if the provided Accept header gives text/html a
+// higher quality than any media range that would match a JSON response.
+// Browsers prefer HTML, while API clients generally accept only JSON.
+func prefersHTML(accept string) bool {
+	htmlQuality := 0.0
+	jsonQuality := 0.0
+
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaRangeType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if rawQuality, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(rawQuality, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		switch mediaRangeType {
+		case "text/html", "application/xhtml+xml":
+			htmlQuality = math.Max(htmlQuality, quality)
+		case "application/json", "application/problem+json", "application/*", "*/*":
+			jsonQuality = math.Max(jsonQuality, quality)
+		}
+	}
+
+	return htmlQuality > jsonQuality
+}
+
+// durationUnitSuffix returns the suffix used by time.Duration.String for the
+// provided unit, or the unit itself in parentheses if it is not a standard
+// unit.
+func durationUnitSuffix(unit time.Duration) string {
+	switch unit {
+	case time.Nanosecond:
+		return "ns"
+	case time.Microsecond:
+		return "µs"
+	case time.Millisecond:
+		return "ms"
+	case time.Second:
+		return "s"
+	case time.Minute:
+		return "m"
+	case time.Hour:
+		return "h"
+	default:
+		return fmt.Sprintf("(%v)", unit)
+	}
+}
@@ -1,6 +1,7 @@
 package web
 
 import (
+	"net/http"
 	"net/http/httptest"
 	"testing"
 
@@ -39,3 +40,164 @@ func TestUnmarshalFromResponseRecorder(t *testing.T) {
 	test.That(t, err).IsNil()
 	test.That(t, m.Name).IsEqualTo("John Smith")
 }
+
+func TestDecodeProblem(t *testing.T) {
+	// Arrange.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx := NewContext(w, r, nil, &Config{ProblemDetailsTypePrefix: "https://testi.ng"})
+	ctx.NotFound("User", "1234")
+	res := w.Result()
+
+	// Act.
+	isProblem := IsProblemResponse(res)
+	details, err := DecodeProblem(res)
+
+	// Assert.
+	test.That(t, isProblem).IsTrue()
+	test.That(t, err).IsNil()
+	test.That(t, details.Type).IsEqualTo("https://testi.ng/http/not-found")
+	test.That(t, details.Title).IsEqualTo("Not Found")
+}
+
+func TestDecodeProblemNotJSON(t *testing.T) {
+	// Arrange.
+	w := httptest.NewRecorder()
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("Not Found"))
+	res := w.Result()
+
+	// Act.
+	isProblem := IsProblemResponse(res)
+	details, err := DecodeProblem(res)
+
+	// Assert.
+	test.That(t, isProblem).IsFalse()
+	test.That(t, err).IsNotNil()
+	test.That(t, details).IsNil()
+}
+
+func TestJSONDepthExceeds(t *testing.T) {
+	testCases := []struct {
+		given    string
+		maxDepth int
+		expected bool
+	}{
+		{given: `{"a":1}`, maxDepth: 1, expected: false},
+		{given: `{"a":[1]}`, maxDepth: 1, expected: true},
+		{given: `[[[]],[[]]]`, maxDepth: 3, expected: false},
+		{given: `[[[[]]]]`, maxDepth: 3, expected: true},
+		{given: `{"a":"[[[[\\"}`, maxDepth: 1, expected: false},
+		{given: `{"a":"\"[[[["}`, maxDepth: 1, expected: false},
+	}
+
+	for _, testCase := range testCases {
+		actual := jsonDepthExceeds([]byte(testCase.given), testCase.maxDepth)
+		test.That(t, actual).IsEqualTo(testCase.expected)
+	}
+}
+
+func TestIsJSONMediaType(t *testing.T) {
+	testCases := []struct {
+		given    string
+		expected bool
+	}{
+		{given: "application/json", expected: true},
+		{given: "application/vnd.acme+json", expected: true},
+		{given: "APPLICATION/PROBLEM+JSON", expected: true},
+		{given: "application/not-json", expected: false},
+		{given: "text/plain+json", expected: false},
+		{given: "", expected: false},
+	}
+
+	for _, testCase := range testCases {
+		actual := isJSONMediaType(testCase.given)
+		test.That(t, actual).IsEqualTo(testCase.expected)
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	testCases := []struct {
+		given    string
+		expected string
+	}{
+		{given: "report.pdf", expected: `attachment; filename="report.pdf"`},
+		{given: `say "hi".txt`, expected: `attachment; filename="say \"hi\".txt"`},
+		{given: "résumé 2020.pdf", expected: `attachment; filename="r_sum_ 2020.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9%202020.pdf`},
+	}
+
+	for _, testCase := range testCases {
+		actual := contentDisposition("attachment", testCase.given)
+		test.That(t, actual).IsEqualTo(testCase.expected)
+	}
+}
+
+func TestPrefersHTML(t *testing.T) {
+	testCases := []struct {
+		given    string
+		expected bool
+	}{
+		{given: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", expected: true},
+		{given: "application/json", expected: false},
+		{given: "application/json, text/html;q=0.5", expected: false},
+		{given: "text/html;q=0.5, */*;q=0.5", expected: false},
+		{given: "*/*", expected: false},
+		{given: "", expected: false},
+	}
+
+	for _, testCase := range testCases {
+		actual := prefersHTML(testCase.given)
+		test.That(t, actual).IsEqualTo(testCase.expected)
+	}
+}
+
+func TestApplyMergePatchPreservesFieldsNotInJSON(t *testing.T) {
+	// Arrange.
+	target := &testMergePatchTarget{
+		ID:      42,
+		secret:  "s",
+		Name:    "Alice",
+		Email:   "alice@example.com",
+		Address: &testMergePatchAddress{City: "Sydney", Postcode: "2000"},
+		Labels:  map[string]string{"a": "1", "b": "2"},
+	}
+
+	patch := map[string]interface{}{
+		"name":    "Bob",
+		"email":   nil,
+		"address": map[string]interface{}{"city": "Perth"},
+		"labels":  map[string]interface{}{"a": nil, "c": "3"},
+	}
+
+	// Act.
+	err := applyMergePatch(target, patch)
+
+	// Assert.
+	test.That(t, err).IsNil()
+	test.That(t, target.ID).IsEqualTo(42)
+	test.That(t, target.secret).IsEqualTo("s")
+	test.That(t, target.Name).IsEqualTo("Bob")
+	test.That(t, target.Email).IsEqualTo("")
+	test.That(t, target.Address.City).IsEqualTo("Perth")
+	test.That(t, target.Address.Postcode).IsEqualTo("2000")
+	test.That(t, len(target.Labels)).IsEqualTo(2)
+	test.That(t, target.Labels["b"]).IsEqualTo("2")
+	test.That(t, target.Labels["c"]).IsEqualTo("3")
+}
+
+// -----------------------------------------------------------------------------
+
+type testMergePatchTarget struct {
+	ID      int `json:"-"`
+	secret  string
+	Name    string                 `json:"name"`
+	Email   string                 `json:"email"`
+	Address *testMergePatchAddress `json:"address"`
+	Labels  map[string]string      `json:"labels"`
+}
+
+type testMergePatchAddress struct {
+	City     string `json:"city"`
+	Postcode string `json:"postcode"`
+}
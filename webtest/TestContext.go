@@ -0,0 +1,37 @@
+// Package webtest provides helpers for unit testing the routes and middleware
+// of package web.
+package webtest
+
+import (
+	"io"
+	"net/http/httptest"
+
+	"github.com/ljpx/di"
+	"github.com/ljpx/web"
+)
+
+// NewTestContext creates a web.Context for a request with the provided method,
+// path and body, along with the httptest.ResponseRecorder that it responds to.
+// The Context has an empty container and a configuration with debugging
+// enabled and a JSON content length limit of 1 MB.  If body is not nil, the
+// Content-Type of the request is set to application/json.
+func NewTestContext(method string, path string, body io.Reader) (*web.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, path, body)
+
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+
+	ctx := web.NewContext(w, r, di.NewContainer(), newTestConfig())
+
+	return ctx, w
+}
+
+func newTestConfig() *web.Config {
+	return &web.Config{
+		DebuggingEnabled:         true,
+		ProblemDetailsTypePrefix: "https://example.com",
+		JSONContentLengthLimit:   1 << 20,
+	}
+}
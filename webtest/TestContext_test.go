@@ -0,0 +1,78 @@
+package webtest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ljpx/problem"
+	"github.com/ljpx/test"
+	"github.com/ljpx/web"
+)
+
+func TestNewTestContextFromJSON(t *testing.T) {
+	// Arrange.
+	ctx, _ := NewTestContext(http.MethodPost, "/greetings", strings.NewReader(`{"name":"World"}`))
+
+	// Act.
+	model := &testGreetingRequest{}
+	passed := ctx.FromJSON(model)
+
+	// Assert.
+	test.That(t, passed).IsTrue()
+	test.That(t, model.Name).IsEqualTo("World")
+}
+
+func TestNewTestContextFromJSONInvalid(t *testing.T) {
+	// Arrange.
+	ctx, w := NewTestContext(http.MethodPost, "/greetings", strings.NewReader(`{"name":""}`))
+
+	// Act.
+	passed := ctx.FromJSON(&testGreetingRequest{})
+
+	// Assert.
+	test.That(t, passed).IsFalse()
+
+	details := &problem.Details{}
+	err := web.UnmarshalFromResponse(w.Result(), details)
+	test.That(t, err).IsNil()
+	test.That(t, w.Code).IsEqualTo(http.StatusUnprocessableEntity)
+	test.That(t, details.Type).IsEqualTo("https://example.com/http/unprocessable-entity")
+}
+
+func TestNewTestContextRespondWithJSON(t *testing.T) {
+	// Arrange.
+	ctx, w := NewTestContext(http.MethodGet, "/greetings", nil)
+
+	// Act.
+	ctx.RespondWithJSON(http.StatusOK, &testGreetingResponse{Message: "Hello, World!"})
+
+	// Assert.
+	test.That(t, w.Code).IsEqualTo(http.StatusOK)
+
+	model := &testGreetingResponse{}
+	err := web.UnmarshalFromResponse(w.Result(), model)
+	test.That(t, err).IsNil()
+	test.That(t, model.Message).IsEqualTo("Hello, World!")
+}
+
+// -----------------------------------------------------------------------------
+
+type testGreetingRequest struct {
+	Name string `json:"name"`
+}
+
+var _ web.Purifiable = &testGreetingRequest{}
+
+func (m *testGreetingRequest) Purify() (string, error) {
+	if m.Name == "" {
+		return "name", fmt.Errorf("is required")
+	}
+
+	return "", nil
+}
+
+type testGreetingResponse struct {
+	Message string `json:"message"`
+}
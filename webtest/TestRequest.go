@@ -0,0 +1,84 @@
+package webtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+)
+
+// TestRequest builds a request to send to a http.Handler in integration tests.
+type TestRequest struct {
+	method string
+	path   string
+	body   io.Reader
+	header http.Header
+	query  url.Values
+	err    error
+}
+
+// NewTestRequest creates a new TestRequest with the provided method and path.
+func NewTestRequest(method string, path string) *TestRequest {
+	return &TestRequest{
+		method: method,
+		path:   path,
+		header: make(http.Header),
+		query:  make(url.Values),
+	}
+}
+
+// WithJSON sets the body of the request to the JSON representation of the
+// provided model, and sets the Content-Type of the request to application/json.
+// If the model cannot be serialized, Do will return the error.
+func (tr *TestRequest) WithJSON(model interface{}) *TestRequest {
+	rawJSON, err := json.Marshal(model)
+	if err != nil {
+		tr.err = err
+		return tr
+	}
+
+	tr.body = bytes.NewReader(rawJSON)
+	tr.header.Set("Content-Type", "application/json")
+
+	return tr
+}
+
+// WithHeader adds a header to the request.
+func (tr *TestRequest) WithHeader(name string, value string) *TestRequest {
+	tr.header.Add(name, value)
+	return tr
+}
+
+// WithQuery adds a query parameter to the request.
+func (tr *TestRequest) WithQuery(name string, value string) *TestRequest {
+	tr.query.Add(name, value)
+	return tr
+}
+
+// Do sends the request to the provided handler and returns the response.
+func (tr *TestRequest) Do(handler http.Handler) (*http.Response, error) {
+	if tr.err != nil {
+		return nil, tr.err
+	}
+
+	r := httptest.NewRequest(tr.method, tr.path, tr.body)
+	for name, values := range tr.header {
+		r.Header[name] = values
+	}
+
+	if len(tr.query) > 0 {
+		query := r.URL.Query()
+		for name, values := range tr.query {
+			query[name] = append(query[name], values...)
+		}
+
+		r.URL.RawQuery = query.Encode()
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	return w.Result(), nil
+}
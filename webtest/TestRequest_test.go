@@ -0,0 +1,93 @@
+package webtest
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ljpx/di"
+	"github.com/ljpx/logging"
+	"github.com/ljpx/test"
+	"github.com/ljpx/web"
+)
+
+type TestRequestFixture struct {
+	handler http.Handler
+}
+
+func SetupTestRequestFixture() *TestRequestFixture {
+	fixture := &TestRequestFixture{}
+
+	builder := web.NewHandlerBuilder(di.NewContainer(), logging.NewDummyLogger(), newTestConfig())
+	builder.Use(&testGreetingRoute{})
+	fixture.handler = builder.Build()
+
+	return fixture
+}
+
+func TestTestRequestPostsJSON(t *testing.T) {
+	// Arrange.
+	fixture := SetupTestRequestFixture()
+
+	// Act.
+	res, err := NewTestRequest(http.MethodPost, "/greetings").
+		WithJSON(&testGreetingRequest{Name: "World"}).
+		WithHeader("X-Greeting", "Hello").
+		WithQuery("punctuation", "!").
+		Do(fixture.handler)
+
+	// Assert.
+	test.That(t, err).IsNil()
+	test.That(t, res.StatusCode).IsEqualTo(http.StatusOK)
+
+	model := &testGreetingResponse{}
+	err = web.UnmarshalFromResponse(res, model)
+	test.That(t, err).IsNil()
+	test.That(t, model.Message).IsEqualTo("Hello, World!")
+}
+
+func TestTestRequestUnserializableJSON(t *testing.T) {
+	// Arrange.
+	fixture := SetupTestRequestFixture()
+
+	// Act.
+	res, err := NewTestRequest(http.MethodPost, "/greetings").
+		WithJSON(make(chan int)).
+		Do(fixture.handler)
+
+	// Assert.
+	test.That(t, err).IsNotNil()
+	test.That(t, res).IsNil()
+}
+
+// -----------------------------------------------------------------------------
+
+type testGreetingRoute struct{}
+
+var _ web.Route = &testGreetingRoute{}
+
+func (*testGreetingRoute) Method() string {
+	return http.MethodPost
+}
+
+func (*testGreetingRoute) Path() string {
+	return "/greetings"
+}
+
+func (*testGreetingRoute) Middleware() []web.Middleware {
+	return nil
+}
+
+func (*testGreetingRoute) Handle(ctx *web.Context) {
+	model := &testGreetingRequest{}
+	if !ctx.FromJSON(model) {
+		return
+	}
+
+	greeting := ctx.Request().Header.Get("X-Greeting")
+	punctuation := ctx.GetQueryParameter("punctuation")
+
+	ctx.RespondWithJSON(http.StatusOK, &testGreetingResponse{
+		Message: fmt.Sprintf("%v, %v%v", greeting, model.Name, punctuation),
+	})
+}